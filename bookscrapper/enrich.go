@@ -0,0 +1,104 @@
+package bookscrapper
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jupy/book-scrapper/bookscrapper/match"
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// Enrich looks the book up on every enabled source it has no link to yet
+// and merges what they know about it into the book. The sources are
+// searched concurrently. The book is expected to carry its Provenance, as
+// the books of FetchLinks and Edition.Merge do.
+//
+// Sources enabled only for other languages are searched by ISBN alone: a
+// title search of a catalogue in another language finds translations
+// rather than the book.
+func (scrapper *Scrapper) Enrich(ctx context.Context, book *model.Book) {
+	own := make(map[string]bool)
+	for _, source := range scrapper.Registry.Enabled(sources.QueryLanguage(book.Name)) {
+		own[source.Name()] = true
+	}
+
+	var missing []sources.Source
+	for _, source := range scrapper.Registry.AllEnabled() {
+		if book.SourceUrl(source.Name()) == "" {
+			missing = append(missing, source)
+		}
+	}
+
+	same := make([]*model.Book, len(missing))
+	var wg sync.WaitGroup
+	for i, source := range missing {
+		wg.Add(1)
+		go func(i int, source sources.Source) {
+			defer wg.Done()
+			if other, ok := scrapper.findSame(ctx, source, book, own[source.Name()]); ok {
+				same[i] = &other
+			}
+		}(i, source)
+	}
+	wg.Wait()
+
+	candidates := []merge.Candidate{{Book: *book}}
+	for i, source := range missing {
+		if same[i] != nil {
+			scrapper.logf("%s: %s\n", source.Name(), same[i].SourceUrl(source.Name()))
+			candidates = append(candidates, merge.Candidate{Source: source.Name(), Book: *same[i]})
+		}
+	}
+	*book = merge.Books(candidates, scrapper.Policy, scrapper.Resolver)
+}
+
+// FindSame searches the source for the same book, first by ISBN and then by
+// title and author.
+func (scrapper *Scrapper) FindSame(ctx context.Context, source sources.Source, book *model.Book) (model.Book, bool) {
+	return scrapper.findSame(ctx, source, book, true)
+}
+
+func (scrapper *Scrapper) findSame(ctx context.Context, source sources.Source, book *model.Book, byTitle bool) (model.Book, bool) {
+	var searches []func() ([]string, error)
+	if isbns := book.Isbns(); len(isbns) > 0 {
+		searches = append(searches, func() ([]string, error) {
+			return source.Search(ctx, isbns[0])
+		})
+	}
+	author := ""
+	if len(book.Authors) > 0 {
+		author = book.Authors[0].LastName
+	}
+	if byTitle {
+		searches = append(searches, func() ([]string, error) {
+			if s, ok := source.(sources.BookSearcher); ok {
+				return s.SearchBook(ctx, book.Name, author)
+			}
+			return source.Search(ctx, strings.TrimSpace(book.Name+" "+author))
+		})
+	}
+
+	for _, search := range searches {
+		links, err := search()
+		if err != nil {
+			scrapper.logf("%s: %v\n", source.Name(), err)
+			continue
+		}
+		for _, link := range links {
+			other, err := source.Fetch(ctx, link)
+			if err == nil && match.SameBook(book, &other) {
+				return other, true
+			}
+		}
+	}
+	return model.Book{}, false
+}
+
+func (scrapper *Scrapper) logf(format string, args ...interface{}) {
+	if scrapper.Env != nil && scrapper.Env.Logf != nil {
+		scrapper.Env.Logf(format, args...)
+	}
+}
@@ -0,0 +1,64 @@
+package bookscrapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// FetchLink scrapes the page with the source the link's domain belongs to,
+// or with the fallback source when there is none.
+func (scrapper *Scrapper) FetchLink(ctx context.Context, link string) (model.Book, error) {
+	source := scrapper.sourceFor(link)
+	if source == nil {
+		return model.Book{}, fmt.Errorf("%w: no source can fetch %s", sources.ErrNotFound, link)
+	}
+	return source.Fetch(ctx, link)
+}
+
+func (scrapper *Scrapper) sourceFor(link string) sources.Source {
+	if source := scrapper.Registry.ForLink(link); source != nil {
+		return source
+	}
+	return scrapper.Fallback
+}
+
+// FetchLinks scrapes several pages describing the same book and merges
+// them into one.
+func (scrapper *Scrapper) FetchLinks(ctx context.Context, links []string) (model.Book, error) {
+	var candidates []merge.Candidate
+	for _, query := range links {
+		link, ok := scrapper.Registry.AsLink(query)
+		if !ok {
+			return model.Book{}, fmt.Errorf("not a link: %s", query)
+		}
+		book, err := scrapper.FetchLink(ctx, link)
+		if err != nil {
+			return model.Book{}, err
+		}
+		candidates = append(candidates, merge.Candidate{Source: scrapper.sourceFor(link).Name(), Book: book})
+	}
+	return merge.Books(candidates, scrapper.Policy, scrapper.Resolver), nil
+}
+
+// LookupIsbn searches every enabled source for the ISBN and sends the
+// books whose ISBN matches it, along with the errors of the sources.
+func (scrapper *Scrapper) LookupIsbn(ctx context.Context, isbn string) <-chan sources.Result {
+	matches := make(chan sources.Result)
+	go func() {
+		defer close(matches)
+		for r := range sources.SearchAll(ctx, scrapper.Registry.AllEnabled(), model.NormalizeIsbn(isbn)) {
+			if r.Err != nil || r.Book.HasIsbn(isbn) {
+				select {
+				case matches <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return matches
+}
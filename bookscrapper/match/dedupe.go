@@ -0,0 +1,219 @@
+// Package match tells which search results are the same edition and how
+// well they match the query.
+package match
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Edition is one edition of a book, as found on one or more stores.
+type Edition struct {
+	Books []model.Book
+	// Sources are the names of the sources the books were found by.
+	Sources []string
+	// Score is the relevance to the query, see RankEditions.
+	Score float64
+}
+
+// Stores returns the names of the stores the edition was found on.
+func (edition *Edition) Stores() []string {
+	var stores []string
+	seen := make(map[string]bool)
+	for _, book := range edition.Books {
+		for _, name := range book.FoundOn() {
+			if !seen[name] {
+				seen[name] = true
+				stores = append(stores, name)
+			}
+		}
+	}
+	sort.Strings(stores)
+	return stores
+}
+
+// Merge combines what every store knows about the edition.
+func (edition *Edition) Merge(policy merge.Policy, resolver merge.Resolver) model.Book {
+	var candidates []merge.Candidate
+	for i, book := range edition.Books {
+		candidates = append(candidates, merge.Candidate{Source: edition.Sources[i], Book: book})
+	}
+	return merge.Books(candidates, policy, resolver)
+}
+
+// GroupEditions groups search results describing the same edition, keeping
+// the order in which the editions were first found.
+func GroupEditions(found []merge.Candidate) []Edition {
+	var editions []Edition
+	for _, c := range found {
+		grouped := false
+		for i := range editions {
+			if editions[i].Includes(&c.Book) {
+				editions[i].Books = append(editions[i].Books, c.Book)
+				editions[i].Sources = append(editions[i].Sources, c.Source)
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			editions = append(editions, Edition{Books: []model.Book{c.Book}, Sources: []string{c.Source}})
+		}
+	}
+	return editions
+}
+
+// Includes reports whether the book is the same edition as one already in
+// the group and does not contradict the ISBN of any other.
+func (edition *Edition) Includes(book *model.Book) bool {
+	same := false
+	for i := range edition.Books {
+		other := &edition.Books[i]
+		if len(book.Isbns()) > 0 && len(other.Isbns()) > 0 && !SameEdition(book, other) {
+			return false
+		}
+		same = same || SameEdition(book, other)
+	}
+	return same
+}
+
+// SameEdition reports whether two search results are the same edition:
+// they share an ISBN or, when one of them has none, their titles and
+// authors are nearly the same.
+func SameEdition(a *model.Book, b *model.Book) bool {
+	x := a.Isbns()
+	y := b.Isbns()
+	if len(x) > 0 && len(y) > 0 {
+		for _, isbn := range y {
+			if a.HasIsbn(isbn) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !sameTitle(a.Name, b.Name) {
+		return false
+	}
+	if len(a.Authors) == 0 || len(b.Authors) == 0 {
+		return true
+	}
+	for _, p := range a.Authors {
+		for _, q := range b.Authors {
+			if Similarity(NormalizeText(p.LastName), NormalizeText(q.LastName)) >= 0.85 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameTitle reports whether the titles have the same words, ignoring case,
+// punctuation and diacritics, with at most a mistyped letter in a long
+// word. Words differing by an ending or a number, as in "Идиот" and
+// "Идиоты" or "Том 1" and "Том 2", make different titles.
+func sameTitle(a string, b string) bool {
+	x := strings.Fields(NormalizeText(a))
+	y := strings.Fields(NormalizeText(b))
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] && !mistyped(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mistyped reports whether the words of five letters or more differ in one
+// letter only.
+func mistyped(a string, b string) bool {
+	x := []rune(a)
+	y := []rune(b)
+	if len(x) != len(y) || len(x) < 5 {
+		return false
+	}
+	diff := 0
+	for i := range x {
+		if x[i] != y[i] {
+			if unicode.IsDigit(x[i]) || unicode.IsDigit(y[i]) {
+				return false
+			}
+			diff++
+		}
+	}
+	return diff == 1
+}
+
+// Similarity is 1 minus the edit distance between the strings relative to
+// the longer one: 1 for equal strings, 0 for entirely different ones.
+func Similarity(a string, b string) float64 {
+	x := []rune(a)
+	y := []rune(b)
+	if len(x) == 0 && len(y) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	longest := len(x)
+	if len(y) > longest {
+		longest = len(y)
+	}
+	return 1 - float64(prev[len(y)])/float64(longest)
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// SameBook reports whether two books describe the same edition, or at
+// least the same work by the same author when ISBNs cannot tell.
+func SameBook(a *model.Book, b *model.Book) bool {
+	for _, isbn := range b.Isbns() {
+		if a.HasIsbn(isbn) {
+			return true
+		}
+	}
+	if NormalizeText(a.Name) != NormalizeText(b.Name) &&
+		NormalizeText(a.InitName) != NormalizeText(b.Name) &&
+		NormalizeText(a.Name) != NormalizeText(b.InitName) {
+		return false
+	}
+	if len(a.Authors) == 0 || len(b.Authors) == 0 {
+		return true
+	}
+	for _, x := range a.Authors {
+		for _, y := range b.Authors {
+			if strings.EqualFold(x.LastName, y.LastName) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+func TestEditionMergeProvenance(t *testing.T) {
+	// the livelib page links to the same book on Google Books, which sorts
+	// first among the links
+	livelib := model.NewBook()
+	livelib.Name = "Мастер и Маргарита"
+	livelib.Isbn = "978-5-17-118366-8"
+	livelib.Year = "2020"
+	livelib.LivelibUrl = "https://www.livelib.ru/book/1"
+	livelib.GoogleBooksUrl = "https://books.google.com/books?id=1"
+	labirint := model.NewBook()
+	labirint.Name = "Мастер и Маргарита"
+	labirint.Isbn = "9785171183668"
+	labirint.Publisher = "АСТ"
+	labirint.LabirintUrl = "https://www.labirint.ru/books/1/"
+
+	editions := GroupEditions([]merge.Candidate{
+		{Source: "livelib", Book: livelib},
+		{Source: "labirint", Book: labirint},
+	})
+	if len(editions) != 1 {
+		t.Fatalf("got %d editions", len(editions))
+	}
+	book := editions[0].Merge(merge.DefaultPolicy(), nil)
+	for field, want := range map[string]string{
+		"Name":      "livelib, labirint",
+		"Year":      "livelib",
+		"Publisher": "labirint",
+	} {
+		if got := book.Provenance[field]; got != want {
+			t.Errorf("%s: got %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"dune", "dune", 1},
+		{"dune", "", 0},
+		{"идиот", "идиоты", 1 - 1.0/6},
+		{"мастер", "мастр", 1 - 1.0/6},
+	}
+	for _, test := range tests {
+		if got := Similarity(test.a, test.b); got != test.want {
+			t.Errorf("%q, %q: got %.3f, want %.3f", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSameEdition(t *testing.T) {
+	withIsbn := func(b *model.Book, isbn string) *model.Book {
+		b.Isbn = isbn
+		return b
+	}
+	tests := []struct {
+		name string
+		a, b *model.Book
+		want bool
+	}{
+		{"ISBN-10 and ISBN-13",
+			withIsbn(newBook("Мастер и Маргарита", ""), "5-389-01686-6"),
+			withIsbn(newBook("Мастер и Маргарита (сборник)", ""), "978-5-389-01686-6"), true},
+		{"other ISBN of the same title",
+			withIsbn(newBook("Мастер и Маргарита", ""), "978-5-389-01686-6"),
+			withIsbn(newBook("Мастер и Маргарита", ""), "978-5-17-118366-0"), false},
+		{"case and punctuation",
+			newBook("Хоббит, или Туда и обратно", "", "Джон Толкин"),
+			newBook("хоббит или туда и обратно", "", "Дж. Р. Р. Толкин"), true},
+		{"ё and е", newBook("Ёжик в тумане", ""), newBook("Ежик в тумане", ""), true},
+		{"mistyped letter", newBook("Мастер и Маргарита", ""), newBook("Мастер и Маргарата", ""), true},
+		{"other ending", newBook("Идиот", "", "Фёдор Достоевский"), newBook("Идиоты", "", "Фёдор Достоевский"), false},
+		{"other volume", newBook("Война и мир. Том 1", ""), newBook("Война и мир. Том 2", ""), false},
+		{"longer title", newBook("Дюна", ""), newBook("Дюна. Мессия Дюны", ""), false},
+		{"other author", newBook("Рассказы", "", "Антон Чехов"), newBook("Рассказы", "", "Иван Бунин"), false},
+	}
+	for _, test := range tests {
+		if got := SameEdition(test.a, test.b); got != test.want {
+			t.Errorf("%s: got %v", test.name, got)
+		}
+	}
+}
+
+func TestGroupEditions(t *testing.T) {
+	candidate := func(source string, name string, isbn string) merge.Candidate {
+		b := newBook(name, "", "Михаил Булгаков")
+		b.Isbn = isbn
+		return merge.Candidate{Source: source, Book: *b}
+	}
+	editions := GroupEditions([]merge.Candidate{
+		candidate("labirint", "Мастер и Маргарита", "978-5-389-01686-6"),
+		candidate("livelib", "Мастер и Маргарита", "978-5-17-118366-0"),
+		candidate("litres", "Мастер и Маргарита", ""),
+		candidate("ozon", "Мастер и Маргарита", "5-389-01686-6"),
+		candidate("chitaigorod", "Мастер и Маргарита. Белая гвардия", ""),
+	})
+	var got [][]string
+	for _, edition := range editions {
+		got = append(got, edition.Sources)
+	}
+	// litres matches labirint by title, but cannot join it together with
+	// livelib, whose ISBN contradicts labirint's
+	want := [][]string{{"labirint", "litres", "ozon"}, {"livelib"}, {"chitaigorod"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package match
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// translit spells Russian letters in Latin the way book titles and author
+// names usually are on English sites.
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// NormalizeText lower-cases the text, folds ё into е, strips diacritics
+// and punctuation and collapses spaces.
+func NormalizeText(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Transliterate spells the Russian letters of the text in Latin.
+func Transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if t, ok := translit[r]; ok {
+			b.WriteString(t)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// matchTokens returns the words of the text in both spellings, so that
+// "Толстой" matches "Tolstoy".
+func matchTokens(s string) []string {
+	tokens := strings.Fields(NormalizeText(s))
+	return append(tokens, strings.Fields(NormalizeText(Transliterate(s)))...)
+}
+
+// coverage is how well the words of a are found among the words of b, from
+// 0 to 1, allowing for typos.
+func coverage(a string, b []string) float64 {
+	words := strings.Fields(NormalizeText(a))
+	if len(words) == 0 || len(b) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, w := range words {
+		best := 0.0
+		for _, variant := range []string{w, NormalizeText(Transliterate(w))} {
+			for _, t := range b {
+				if s := Similarity(variant, t); s > best {
+					best = s
+				}
+			}
+		}
+		if best < 0.7 {
+			best = 0
+		}
+		total += best
+	}
+	return total / float64(len(words))
+}
+
+// Relevance scores how well the book matches the query, from 0 to 1. The
+// query words are looked for in the title, original title and author names,
+// and the title words are looked for in the query. The two shares are
+// multiplied, so that a title with words the query lacks, such as the
+// sequel "Dune Messiah" for "Dune", scores no more than half. An ISBN query
+// matches only books with that ISBN.
+func Relevance(book *model.Book, query string) float64 {
+	if model.IsIsbn(query) {
+		if book.HasIsbn(query) {
+			return 1
+		}
+		return 0
+	}
+
+	names := book.Name + " " + book.InitName
+	for _, a := range book.Authors {
+		names += " " + a.FirstName + " " + a.LastName
+	}
+	found := coverage(query, matchTokens(names))
+
+	queryTokens := matchTokens(query)
+	title := coverage(book.Name, queryTokens)
+	if s := coverage(book.InitName, queryTokens); s > title {
+		title = s
+	}
+	return found * title
+}
+
+// RankEditions scores the editions against the query and sorts them, best
+// first. An edition scores as well as its best matching store page.
+func RankEditions(editions []Edition, query string) {
+	for i := range editions {
+		editions[i].Score = 0
+		for j := range editions[i].Books {
+			if s := Relevance(&editions[i].Books[j], query); s > editions[i].Score {
+				editions[i].Score = s
+			}
+		}
+	}
+	sort.SliceStable(editions, func(i, j int) bool {
+		return editions[i].Score > editions[j].Score
+	})
+}
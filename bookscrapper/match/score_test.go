@@ -0,0 +1,89 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// autoThreshold is the default relevance at which book picks a search
+// result without asking.
+const autoThreshold = 0.6
+
+// newBook makes a book with the title, original title and authors.
+func newBook(name string, initName string, authors ...string) *model.Book {
+	b := model.NewBook()
+	b.Name = name
+	b.InitName = initName
+	for _, a := range authors {
+		b.Authors = append(b.Authors, model.ParsePerson(a, true))
+	}
+	return &b
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := map[string]string{
+		"Мастер и Маргарита":         "мастер и маргарита",
+		"Ёжик в тумане":              "ежик в тумане",
+		"Les Misérables":             "les miserables",
+		"Хоббит, или Туда и обратно": "хоббит или туда и обратно",
+		"  Dune:  Messiah!  ":        "dune messiah",
+	}
+	for text, want := range tests {
+		if got := NormalizeText(text); got != want {
+			t.Errorf("%q: got %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestRelevance(t *testing.T) {
+	tests := []struct {
+		name  string
+		book  *model.Book
+		query string
+		auto  bool
+	}{
+		{"case", newBook("Мастер и Маргарита", "", "Михаил Булгаков"), "мастер и маргарита", true},
+		{"title and author", newBook("Мастер и Маргарита", "", "Михаил Булгаков"), "Булгаков Мастер и Маргарита", true},
+		{"ё and е", newBook("Ёжик в тумане", "", "Сергей Козлов"), "ежик в тумане", true},
+		{"diacritics", newBook("Les Misérables", "", "Victor Hugo"), "les miserables hugo", true},
+		{"typo", newBook("Мастер и Маргарита", ""), "Мастер и Маргарто", true},
+		{"Latin query for a Cyrillic book", newBook("Анна Каренина", "", "Лев Толстой"), "Anna Karenina Tolstoy", true},
+		{"original title", newBook("Дюна", "Dune", "Фрэнк Герберт"), "Dune", true},
+		{"sequel", newBook("Dune Messiah", "", "Frank Herbert"), "Dune", false},
+		{"sequel with the author", newBook("Dune Messiah", "", "Frank Herbert"), "Dune Herbert", false},
+		{"sequel in Russian", newBook("Мессия Дюны", "Dune Messiah", "Фрэнк Герберт"), "Дюна", false},
+		{"title starting with the query", newBook("Хоббит, или Туда и обратно", "", "Джон Толкин"), "Хоббит", false},
+		{"only the author", newBook("Мастер и Маргарита", "", "Михаил Булгаков"), "Булгаков", false},
+		{"other book", newBook("Белая гвардия", "", "Михаил Булгаков"), "Мастер и Маргарита", false},
+	}
+	for _, test := range tests {
+		score := Relevance(test.book, test.query)
+		if (score >= autoThreshold) != test.auto {
+			t.Errorf("%s: %q for %q scores %.2f", test.name, test.query, test.book.Name, score)
+		}
+	}
+}
+
+func TestRelevanceIsbn(t *testing.T) {
+	b := newBook("Мастер и Маргарита", "")
+	b.Isbn = "978-5-389-01686-6"
+	if got := Relevance(b, "9785389016866"); got != 1 {
+		t.Errorf("same ISBN: got %.2f", got)
+	}
+	if got := Relevance(b, "978-5-17-118366-0"); got != 0 {
+		t.Errorf("other ISBN: got %.2f", got)
+	}
+}
+
+func TestRankEditions(t *testing.T) {
+	editions := []Edition{
+		{Books: []model.Book{*newBook("Dune Messiah", "", "Frank Herbert")}},
+		{Books: []model.Book{*newBook("Children of Dune", "", "Frank Herbert")}},
+		{Books: []model.Book{*newBook("Дюна", "", "Фрэнк Герберт"), *newBook("Dune", "", "Frank Herbert")}},
+	}
+	RankEditions(editions, "Dune Herbert")
+	if got := editions[0].Books[0].Name; got != "Дюна" || editions[0].Score != 1 {
+		t.Errorf("got %q first with %.2f", got, editions[0].Score)
+	}
+}
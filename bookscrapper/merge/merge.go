@@ -0,0 +1,381 @@
+// Package merge combines the descriptions of a book by several sources into
+// one, field by field.
+package merge
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Strategy decides which value of a field wins when several sources
+// describe the same book.
+type Strategy string
+
+const (
+	// PreferFirst takes the value of the first source that has one.
+	PreferFirst Strategy = "first"
+	// PreferSource takes the value of Rule.Source, falling back to
+	// PreferFirst when that source has none.
+	PreferSource Strategy = "prefer"
+	// Longest takes the longest value, e.g. the most complete summary.
+	Longest Strategy = "longest"
+	// Union combines the values of all sources. Only valid for lists and
+	// maps.
+	Union Strategy = "union"
+	// Majority takes the value most sources agree on, ties going to the
+	// first source.
+	Majority Strategy = "majority"
+)
+
+type Rule struct {
+	Strategy Strategy `json:"strategy"`
+	Source   string   `json:"source,omitempty"`
+	// Ask lets the resolver settle the field when the sources disagree.
+	Ask bool `json:"ask,omitempty"`
+}
+
+// Policy maps Book field names to merge rules. Fields without a rule
+// are merged with PreferFirst.
+type Policy map[string]Rule
+
+func DefaultPolicy() Policy {
+	return Policy{
+		"Name":        {Strategy: PreferFirst, Ask: true},
+		"InitName":    {Strategy: PreferSource, Source: "goodreads", Ask: true},
+		"Year":        {Strategy: Majority, Ask: true},
+		"FirstYear":   {Strategy: Majority},
+		"Publisher":   {Strategy: PreferFirst, Ask: true},
+		"Series":      {Strategy: PreferFirst, Ask: true},
+		"Isbn":        {Strategy: PreferFirst},
+		"Summary":     {Strategy: Longest},
+		"Genres":      {Strategy: Union},
+		"Tags":        {Strategy: Union},
+		"Authors":     {Strategy: Union},
+		"Painters":    {Strategy: Union},
+		"Editors":     {Strategy: Union},
+		"Translators": {Strategy: Union},
+		"Countries":   {Strategy: Union},
+		"Awards":      {Strategy: Union},
+		"Ratings":     {Strategy: Union},
+	}
+}
+
+// Candidate is a book as one source describes it. A book merged before
+// has no source of its own: its Provenance tells where its values came
+// from.
+type Candidate struct {
+	Source string
+	Book   model.Book
+}
+
+// Option is one of the values the sources disagree on.
+type Option struct {
+	Value   string
+	Sources []string
+	key     string
+}
+
+// Resolver settles conflicts for fields whose rule asks for it. It gets the
+// distinct values and the index the strategy would pick, and returns the
+// index of the value to keep.
+type Resolver interface {
+	Resolve(field string, options []Option, pick int) int
+}
+
+// notMerged are the Book fields that are not book data.
+var notMerged = map[string]bool{
+	"FileName":   true,
+	"ShortName":  true,
+	"Provenance": true,
+}
+
+// Books combines the candidates into one book, field by field, and
+// records in its Provenance which sources each value came from. The
+// resolver may be nil to merge without asking.
+func Books(candidates []Candidate, policy Policy, resolver Resolver) model.Book {
+	book := model.NewBook()
+	if len(candidates) == 0 {
+		return book
+	}
+
+	dst := reflect.ValueOf(&book).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i).Name
+		if notMerged[field] {
+			continue
+		}
+		rule, ok := policy[field]
+		if !ok {
+			rule = Rule{Strategy: PreferFirst}
+		}
+
+		// labels are what provenance records: a candidate that is a merge
+		// itself passes on where its values came from
+		var values []reflect.Value
+		var labels []string
+		for _, c := range candidates {
+			v := reflect.ValueOf(c.Book).Field(i)
+			if !v.IsZero() && v.Len() > 0 {
+				values = append(values, v)
+				if p := c.Book.Provenance[field]; p != "" {
+					labels = append(labels, p)
+				} else {
+					labels = append(labels, c.Source)
+				}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if _, ok := values[0].Interface().([]model.Person); ok {
+			values, labels = sameScript(values, labels)
+		}
+
+		var from []string
+		if rule.Strategy == Union && dst.Field(i).Kind() != reflect.String {
+			dst.Field(i).Set(unionValues(values))
+			from = labels
+		} else {
+			pick := pickValue(rule, values, labels)
+			options := distinctOptions(values, labels)
+			if rule.Ask && resolver != nil && len(options) > 1 {
+				n := resolver.Resolve(field, options, optionIndex(options, values[pick]))
+				if n >= 0 && n < len(options) {
+					pick = valueIndex(values, options[n])
+				}
+			}
+			dst.Field(i).Set(values[pick])
+			for n, v := range values {
+				if valueKey(v) == valueKey(values[pick]) {
+					from = append(from, labels[n])
+				}
+			}
+		}
+		if field != "Type" && field != "Ratings" && !strings.HasSuffix(field, "Url") {
+			book.Provenance[field] = joinSources(from)
+		}
+	}
+
+	book.InitFileName()
+	return book
+}
+
+// joinSources lists each source once, e.g. "labirint, livelib".
+func joinSources(labels []string) string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, label := range labels {
+		for _, s := range strings.Split(label, ", ") {
+			if !seen[s] {
+				seen[s] = true
+				list = append(list, s)
+			}
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+func pickValue(rule Rule, values []reflect.Value, labels []string) int {
+	switch rule.Strategy {
+	case PreferSource:
+		for i, label := range labels {
+			if contains(strings.Split(label, ", "), rule.Source) {
+				return i
+			}
+		}
+	case Longest:
+		best := 0
+		for i, v := range values {
+			if valueLength(v) > valueLength(values[best]) {
+				best = i
+			}
+		}
+		return best
+	case Majority:
+		count := make(map[string]int)
+		for _, v := range values {
+			count[valueKey(v)]++
+		}
+		best := 0
+		for i, v := range values {
+			if count[valueKey(v)] > count[valueKey(values[best])] {
+				best = i
+			}
+		}
+		return best
+	}
+	return 0
+}
+
+func valueLength(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+	return v.Len()
+}
+
+// valueKey is a comparable form of a field value, ignoring case and
+// surrounding space.
+func valueKey(v reflect.Value) string {
+	return strings.ToLower(strings.TrimSpace(formatValue(v)))
+}
+
+func formatValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case string:
+		return x
+	case []string:
+		return strings.Join(x, ", ")
+	case []model.Person:
+		var names []string
+		for _, p := range x {
+			names = append(names, p.PrintName())
+		}
+		return strings.Join(names, "; ")
+	case map[string]string:
+		var keys []string
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return strings.Join(keys, ", ")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func distinctOptions(values []reflect.Value, labels []string) []Option {
+	var options []Option
+	index := make(map[string]int)
+	for i, v := range values {
+		key := valueKey(v)
+		n, ok := index[key]
+		if !ok {
+			n = len(options)
+			index[key] = n
+			options = append(options, Option{Value: formatValue(v), key: key})
+		}
+		for _, s := range strings.Split(labels[i], ", ") {
+			if !contains(options[n].Sources, s) {
+				options[n].Sources = append(options[n].Sources, s)
+			}
+		}
+	}
+	return options
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func optionIndex(options []Option, v reflect.Value) int {
+	for i, o := range options {
+		if o.key == valueKey(v) {
+			return i
+		}
+	}
+	return 0
+}
+
+func valueIndex(values []reflect.Value, option Option) int {
+	for i, v := range values {
+		if valueKey(v) == option.key {
+			return i
+		}
+	}
+	return 0
+}
+
+func unionValues(values []reflect.Value) reflect.Value {
+	switch values[0].Interface().(type) {
+	case []string:
+		var list []string
+		seen := make(map[string]bool)
+		for _, v := range values {
+			for _, s := range v.Interface().([]string) {
+				if key := strings.ToLower(s); !seen[key] {
+					seen[key] = true
+					list = append(list, s)
+				}
+			}
+		}
+		return reflect.ValueOf(list)
+	case []model.Person:
+		var persons []model.Person
+		index := make(map[string]int)
+		for _, v := range values {
+			for _, p := range v.Interface().([]model.Person) {
+				key := personKey(p)
+				if n, ok := index[key]; ok {
+					if len(p.FirstName) > len(persons[n].FirstName) {
+						persons[n] = p
+					}
+					continue
+				}
+				index[key] = len(persons)
+				persons = append(persons, p)
+			}
+		}
+		return reflect.ValueOf(persons)
+	case map[string]string:
+		m := make(map[string]string)
+		for _, v := range values {
+			for k, s := range v.Interface().(map[string]string) {
+				if m[k] == "" {
+					m[k] = s
+				}
+			}
+		}
+		return reflect.ValueOf(m)
+	}
+	return values[0]
+}
+
+// sameScript keeps the person lists written in the same script as the
+// first one, as "Булгаков, М." and "Bulgakov, M." are the same person
+// spelled twice. The first candidate is the source of the language of the
+// book.
+func sameScript(values []reflect.Value, labels []string) ([]reflect.Value, []string) {
+	script := personScript(values[0].Interface().([]model.Person))
+	var keptValues []reflect.Value
+	var keptLabels []string
+	for i, v := range values {
+		if personScript(v.Interface().([]model.Person)) == script {
+			keptValues = append(keptValues, v)
+			keptLabels = append(keptLabels, labels[i])
+		}
+	}
+	return keptValues, keptLabels
+}
+
+// personScript tells whether the names are written in Cyrillic.
+func personScript(persons []model.Person) bool {
+	for _, p := range persons {
+		for _, r := range p.LastName + p.FirstName {
+			if unicode.IsLetter(r) {
+				return unicode.Is(unicode.Cyrillic, r)
+			}
+		}
+	}
+	return false
+}
+
+// personKey identifies a person across differently spelled names: "Толстой,
+// Лев Николаевич" and "Толстой Л.Н." are the same person.
+func personKey(p model.Person) string {
+	initial := model.FirstRune(p.FirstName)
+	if initial == 0 {
+		initial = model.FirstRune(p.Initials)
+	}
+	return strings.ToLower(p.LastName + " " + string(initial))
+}
@@ -0,0 +1,233 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// candidate makes a candidate of the source with the fields set by fill.
+func candidate(source string, fill func(book *model.Book)) Candidate {
+	book := model.NewBook()
+	fill(&book)
+	return Candidate{Source: source, Book: book}
+}
+
+func TestStrategies(t *testing.T) {
+	candidates := []Candidate{
+		candidate("labirint", func(b *model.Book) {
+			b.Name = "Мастер и Маргарита"
+			b.Year = "2019"
+			b.Summary = "Роман."
+			b.Awards = []string{"Премия"}
+			b.Genres["роман"] = ""
+		}),
+		candidate("livelib", func(b *model.Book) {
+			b.Name = "Мастер и Маргарита (сборник)"
+			b.InitName = "Master i Margarita"
+			b.Year = "2020"
+			b.Summary = "Роман о дьяволе в Москве."
+			b.Awards = []string{"премия", "Другая премия"}
+			b.Genres["классика"] = ""
+		}),
+		candidate("goodreads", func(b *model.Book) {
+			b.InitName = "The Master and Margarita"
+			b.Year = "2020"
+		}),
+	}
+	book := Books(candidates, DefaultPolicy(), nil)
+
+	tests := []struct {
+		strategy Strategy
+		got      interface{}
+		want     interface{}
+	}{
+		{PreferFirst, book.Name, "Мастер и Маргарита"},
+		{PreferSource, book.InitName, "The Master and Margarita"},
+		{Majority, book.Year, "2020"},
+		{Longest, book.Summary, "Роман о дьяволе в Москве."},
+		{Union, book.Awards, []string{"Премия", "Другая премия"}},
+		{Union, book.Genres, map[string]string{"роман": "", "классика": ""}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.strategy, test.got, test.want)
+		}
+	}
+}
+
+func TestPreferSourceFallsBack(t *testing.T) {
+	policy := Policy{"InitName": {Strategy: PreferSource, Source: "goodreads"}}
+	book := Books([]Candidate{
+		candidate("labirint", func(b *model.Book) {}),
+		candidate("livelib", func(b *model.Book) { b.InitName = "Master i Margarita" }),
+	}, policy, nil)
+	if book.InitName != "Master i Margarita" {
+		t.Errorf("got %q", book.InitName)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	tests := []struct {
+		name    string
+		authors [][]string
+		want    []string
+	}{
+		{
+			"spellings of the same person",
+			[][]string{{"Л.Н. Толстой"}, {"Лев Николаевич Толстой"}},
+			[]string{"Толстой, Лев"},
+		},
+		{
+			"co-authors are combined",
+			[][]string{{"Аркадий Стругацкий"}, {"Аркадий Стругацкий", "Борис Стругацкий"}},
+			[]string{"Стругацкий, Аркадий", "Стругацкий, Борис"},
+		},
+		{
+			"other scripts are not combined",
+			[][]string{{"М. Булгаков"}, {"M. Bulgakov"}, {"Михаил Булгаков"}},
+			[]string{"Булгаков, Михаил"},
+		},
+		{
+			"the first candidate sets the script",
+			[][]string{{"Mikhail Bulgakov"}, {"М. Булгаков"}},
+			[]string{"Bulgakov, Mikhail"},
+		},
+	}
+	sources := []string{"labirint", "goodreads", "livelib"}
+	for _, test := range tests {
+		var candidates []Candidate
+		for i, names := range test.authors {
+			candidates = append(candidates, candidate(sources[i], func(b *model.Book) {
+				for _, name := range names {
+					b.Authors = append(b.Authors, model.ParsePerson(name, true))
+				}
+			}))
+		}
+		book := Books(candidates, DefaultPolicy(), nil)
+		var got []string
+		for _, p := range book.Authors {
+			got = append(got, p.PrintName())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// fixedResolver picks the option with the value, recording what it was
+// asked.
+type fixedResolver struct {
+	value   string
+	fields  []string
+	options []Option
+	pick    int
+}
+
+func (r *fixedResolver) Resolve(field string, options []Option, pick int) int {
+	r.fields = append(r.fields, field)
+	r.options = options
+	r.pick = pick
+	for i, o := range options {
+		if o.Value == r.value {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestResolver(t *testing.T) {
+	candidates := []Candidate{
+		candidate("labirint", func(b *model.Book) { b.Publisher = "АСТ"; b.Isbn = "1" }),
+		candidate("livelib", func(b *model.Book) { b.Publisher = "Азбука"; b.Isbn = "2" }),
+		candidate("litres", func(b *model.Book) { b.Publisher = "азбука " }),
+	}
+
+	resolver := &fixedResolver{value: "Азбука"}
+	book := Books(candidates, DefaultPolicy(), resolver)
+	if book.Publisher != "Азбука" {
+		t.Errorf("got publisher %q", book.Publisher)
+	}
+	// Isbn does not ask, and fields the sources agree on are not asked
+	if !reflect.DeepEqual(resolver.fields, []string{"Publisher"}) {
+		t.Errorf("asked about %q", resolver.fields)
+	}
+	want := []Option{
+		{Value: "АСТ", Sources: []string{"labirint"}, key: "аст"},
+		{Value: "Азбука", Sources: []string{"livelib", "litres"}, key: "азбука"},
+	}
+	if !reflect.DeepEqual(resolver.options, want) || resolver.pick != 0 {
+		t.Errorf("got options %+v, pick %d", resolver.options, resolver.pick)
+	}
+	if book.Provenance["Publisher"] != "livelib, litres" {
+		t.Errorf("got provenance %q", book.Provenance["Publisher"])
+	}
+
+	// an answer out of range keeps the value of the strategy
+	book = Books(candidates, DefaultPolicy(), &fixedResolver{value: "Эксмо"})
+	if book.Publisher != "АСТ" {
+		t.Errorf("got publisher %q", book.Publisher)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	candidates := []Candidate{
+		candidate("labirint", func(b *model.Book) {
+			b.Year = "2019"
+			b.Tags["мистика"] = ""
+			b.LabirintUrl = "https://www.labirint.ru/books/1/"
+			b.SetRating("labirint", "9")
+		}),
+		candidate("livelib", func(b *model.Book) {
+			b.Year = "2020"
+			b.Tags["классика"] = ""
+		}),
+		candidate("litres", func(b *model.Book) {
+			b.Year = "2020"
+			b.Authors = []model.Person{model.ParsePerson("Михаил Булгаков", true)}
+		}),
+		candidate("goodreads", func(b *model.Book) {
+			b.Authors = []model.Person{model.ParsePerson("Mikhail Bulgakov", true)}
+		}),
+	}
+	book := Books(candidates, DefaultPolicy(), nil)
+	want := map[string]string{
+		"Year":    "livelib, litres",
+		"Tags":    "labirint, livelib",
+		"Authors": "litres",
+	}
+	if !reflect.DeepEqual(book.Provenance, want) {
+		t.Errorf("got %q, want %q", book.Provenance, want)
+	}
+
+	// a merged book passes on where its values came from
+	merged := Candidate{Source: "labirint", Book: book}
+	book = Books([]Candidate{merged, candidate("chitaigorod", func(b *model.Book) { b.Year = "2020" })}, DefaultPolicy(), nil)
+	if got := book.Provenance["Year"]; got != "livelib, litres, chitaigorod" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMergedCandidate(t *testing.T) {
+	merged := Books([]Candidate{
+		candidate("livelib", func(b *model.Book) { b.Publisher = "Азбука" }),
+		candidate("goodreads", func(b *model.Book) { b.InitName = "The Master and Margarita" }),
+	}, DefaultPolicy(), nil)
+
+	resolver := &fixedResolver{}
+	book := Books([]Candidate{
+		{Book: merged},
+		candidate("labirint", func(b *model.Book) {
+			b.Publisher = "АСТ"
+			b.InitName = "Master i Margarita"
+		}),
+	}, DefaultPolicy(), resolver)
+	// the merged book is preferred for goodreads, which it came from
+	if book.InitName != "The Master and Margarita" {
+		t.Errorf("got %q", book.InitName)
+	}
+	if resolver.options[0].Sources[0] != "livelib" {
+		t.Errorf("got options %+v", resolver.options)
+	}
+}
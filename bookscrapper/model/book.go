@@ -0,0 +1,372 @@
+// Package model holds the book data the sources scrape and the notes are
+// written from.
+package model
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Person struct {
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Initials   string
+}
+
+func (person *Person) PrintName() string {
+	if person.FirstName == "" && person.Initials != "" {
+		return person.LastName + " " + person.Initials
+	} else {
+		return person.LastName + ", " + person.FirstName
+	}
+}
+
+type Book struct {
+	Type      string
+	FileName  string
+	ShortName string
+	Name      string
+	InitName  string
+	PosterUrl string
+	Year      string
+	// FirstYear is the year the work was first published, for sources that
+	// tell it apart from the year of the edition.
+	FirstYear string
+	Genres    map[string]string
+	Tags      map[string]string
+	Series    string
+	// Cycle is the cycle of works the book belongs to and CycleNumber its
+	// place in it, unlike Series which is usually the publisher's series.
+	Cycle       string
+	CycleNumber string
+	Authors     []Person
+	Painters    []Person
+	Editors     []Person
+	Translators []Person
+	Countries   []string
+	// Awards are the awards the work won.
+	Awards         []string
+	Publisher      string
+	Isbn           string
+	Summary        string
+	LabirintUrl    string
+	GoodreadsUrl   string
+	FlibustaUrl    string
+	LitresUrl      string
+	LivelibUrl     string
+	OpenLibraryUrl string
+	GoogleBooksUrl string
+	FantlabUrl     string
+	ChitaiGorodUrl string
+	OzonUrl        string
+	// OtherUrl is the page of a site no source knows, read by the generic
+	// source.
+	OtherUrl string
+	// Ratings maps a source name to the book's average rating there.
+	Ratings map[string]string
+	// Provenance maps a field name to the source that supplied its value.
+	Provenance map[string]string
+}
+
+func NewBook() Book {
+	var book Book
+	book.Type = "book"
+	book.Genres = make(map[string]string)
+	book.Tags = make(map[string]string)
+	book.Ratings = make(map[string]string)
+	book.Provenance = make(map[string]string)
+	return book
+}
+
+// Print lists the fields of the book, one per line.
+func (book *Book) Print(w io.Writer) {
+	fmt.Fprintf(w, "Name:           %s\n", book.Name)
+	fmt.Fprintf(w, "Original Title: %s\n", book.InitName)
+	fmt.Fprintf(w, "Year:           %s\n", book.Year)
+	if book.FirstYear != "" {
+		fmt.Fprintf(w, "First Year:     %s\n", book.FirstYear)
+	}
+	fmt.Fprintf(w, "Picture:        %s\n", book.PosterUrl)
+	for _, a := range book.Genres {
+		fmt.Fprintf(w, "Genre:          [%s]\n", book.GetGenre(a))
+	}
+	for _, d := range book.Authors {
+		fmt.Fprintf(w, "Author:       %s, %s\n", d.LastName, d.FirstName)
+	}
+	for _, d := range book.Painters {
+		fmt.Fprintf(w, "Painter:      %s, %s\n", d.LastName, d.FirstName)
+	}
+	for _, d := range book.Editors {
+		fmt.Fprintf(w, "Editor:       %s, %s\n", d.LastName, d.FirstName)
+	}
+	for _, d := range book.Translators {
+		fmt.Fprintf(w, "Translators:  %s, %s\n", d.LastName, d.FirstName)
+	}
+	fmt.Fprintf(w, "Publisher:      %s\n", book.Publisher)
+	for _, c := range book.Countries {
+		fmt.Fprintf(w, "Country:        %s\n", c)
+	}
+	for _, t := range book.Tags {
+		fmt.Fprintf(w, "Tag:            [%s]\n", book.GetTag(t))
+	}
+
+	fmt.Fprintf(w, "Series:         %s\n", book.Series)
+	if book.Cycle != "" {
+		fmt.Fprintf(w, "Cycle:          %s %s\n", book.Cycle, book.CycleNumber)
+	}
+	for _, a := range book.Awards {
+		fmt.Fprintf(w, "Award:          %s\n", a)
+	}
+	fmt.Fprintf(w, "ISBN:           %s\n", book.Isbn)
+	fmt.Fprintf(w, "Labirint:       %s\n", book.LabirintUrl)
+	fmt.Fprintf(w, "Goodreads:      %s\n", book.GoodreadsUrl)
+	fmt.Fprintf(w, "Flibusta:       %s\n", book.FlibustaUrl)
+	fmt.Fprintf(w, "Litres:         %s\n", book.LitresUrl)
+	if book.LivelibUrl != "" {
+		fmt.Fprintf(w, "Livelib:      %s\n", book.LivelibUrl)
+	}
+	if book.OpenLibraryUrl != "" {
+		fmt.Fprintf(w, "Open Library: %s\n", book.OpenLibraryUrl)
+	}
+	if book.GoogleBooksUrl != "" {
+		fmt.Fprintf(w, "Google Books: %s\n", book.GoogleBooksUrl)
+	}
+	if book.FantlabUrl != "" {
+		fmt.Fprintf(w, "Fantlab:      %s\n", book.FantlabUrl)
+	}
+	if book.ChitaiGorodUrl != "" {
+		fmt.Fprintf(w, "Chitai-gorod: %s\n", book.ChitaiGorodUrl)
+	}
+	if book.OzonUrl != "" {
+		fmt.Fprintf(w, "Ozon:         %s\n", book.OzonUrl)
+	}
+	if book.OtherUrl != "" {
+		fmt.Fprintf(w, "Other:        %s\n", book.OtherUrl)
+	}
+	for source, rating := range book.Ratings {
+		fmt.Fprintf(w, "Rating:         %s %s\n", rating, source)
+	}
+
+	fmt.Fprintf(w, "Summary:\n")
+	fmt.Fprintf(w, "%s\n", book.Summary)
+}
+
+func IsEnglish(s string) bool {
+	if len(s) <= 0 {
+		return false
+	}
+	r := s[0]
+	if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+		return false
+	}
+	return true
+}
+
+func (book *Book) GetPrintAuthor() string {
+	var author string
+	sort.Slice(book.Authors, func(i, j int) bool {
+		return book.Authors[i].LastName < book.Authors[j].LastName
+	})
+	/* fmt.Printf("author: %v\n", book.Authors) */
+	l := len(book.Authors)
+	if l > 0 {
+		author = book.Authors[0].PrintName()
+	}
+	if l == 2 {
+		a := book.Authors[1].PrintName()
+		if IsEnglish(a) {
+			author += " and " + a
+		} else {
+			author += " и " + a
+		}
+	}
+	if l > 2 {
+		if IsEnglish(author) {
+			author += " et al"
+		} else {
+			author += " и др."
+		}
+	}
+	return author
+}
+
+func (book *Book) InitFileName() {
+	var name string
+	if utf8.RuneCountInString(book.Name) <= 75 {
+		name = book.Name
+	} else if strings.Contains(book.Name, ".") {
+		v := strings.Split(book.Name, ".")
+		name = v[0]
+	} else {
+		name = book.Name[0:72] + "..."
+	}
+
+	name = book.GetPrintAuthor() + " - " + name + ".md"
+	name = strings.ReplaceAll(name, "<", "")
+	name = strings.ReplaceAll(name, ">", "")
+	name = strings.ReplaceAll(name, ":", " -")
+	name = strings.ReplaceAll(name, "«", "")
+	name = strings.ReplaceAll(name, "»", "")
+	name = strings.ReplaceAll(name, "/", "-")
+	name = strings.ReplaceAll(name, "\\", "-")
+	name = strings.ReplaceAll(name, "|", "-")
+	name = strings.ReplaceAll(name, "?", ".")
+	name = strings.ReplaceAll(name, "*", "")
+	book.FileName = name
+}
+
+// FirstRune returns the first letter of the string, or 0 if it is empty.
+func FirstRune(str string) (r rune) {
+	for _, r = range str {
+		return
+	}
+	return
+}
+
+func (book *Book) GetGenre(genre string) string {
+	trans := book.Genres[genre]
+	if trans == "" && trans != "#" {
+		return trans + "|" + genre
+	} else {
+		return genre
+	}
+}
+
+func (book *Book) GetTag(tag string) string {
+	trans := book.Tags[tag]
+	if trans == "" && trans != "#" {
+		return trans + "|" + tag
+	} else {
+		return tag
+	}
+}
+
+// SetRating records the rating shown by a source, ignoring empty values.
+func (book *Book) SetRating(source string, rating string) {
+	rating = strings.TrimSpace(rating)
+	if rating != "" {
+		book.Ratings[source] = strings.ReplaceAll(rating, ",", ".")
+	}
+}
+
+func (book *Book) sourceUrls() map[string]*string {
+	return map[string]*string{
+		"labirint":    &book.LabirintUrl,
+		"goodreads":   &book.GoodreadsUrl,
+		"flibusta":    &book.FlibustaUrl,
+		"litres":      &book.LitresUrl,
+		"livelib":     &book.LivelibUrl,
+		"openlibrary": &book.OpenLibraryUrl,
+		"googlebooks": &book.GoogleBooksUrl,
+		"fantlab":     &book.FantlabUrl,
+		"chitaigorod": &book.ChitaiGorodUrl,
+		"ozon":        &book.OzonUrl,
+		"generic":     &book.OtherUrl,
+	}
+}
+
+// SourceUrl returns the link to the book on the named source.
+func (book *Book) SourceUrl(source string) string {
+	if u, ok := book.sourceUrls()[source]; ok {
+		return *u
+	}
+	return ""
+}
+
+func (book *Book) SetSourceUrl(source string, link string) {
+	if u, ok := book.sourceUrls()[source]; ok {
+		*u = link
+	}
+}
+
+// FoundOn returns the names of the sources the book has a link to.
+func (book *Book) FoundOn() []string {
+	var names []string
+	for name, u := range book.sourceUrls() {
+		if *u != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ParsePerson(str string, invert bool) Person {
+	/* fmt.Printf("person: %s\n", str) */
+	var person Person
+	var v []string
+	appendToLast := ""
+	vec := strings.Split(str, " ")
+	for _, item := range vec {
+		s := strings.TrimSpace(item)
+		if s == "" {
+			continue
+		}
+		r := []rune(s)
+		count := utf8.RuneCountInString(s)
+		if unicode.IsUpper(FirstRune(s)) {
+			if (count == 1) ||
+				(count == 2 && r[1] == '.') ||
+				(count == 4 && r[1] == '.' && r[3] == '.') {
+				person.Initials += s
+			} else {
+				v = append(v, s)
+			}
+		} else {
+			l := len(v)
+			if l > 0 {
+				v[l-1] += " " + s
+				invert = !invert
+			} else {
+				appendToLast = s
+			}
+		}
+	}
+
+	if appendToLast != "" {
+		l := len(v)
+		if l > 0 {
+			v[l-1] += " " + appendToLast
+		}
+	}
+
+	if len(v) == 1 {
+		person.LastName = v[0]
+	} else if len(v) == 2 {
+		if invert {
+			person.FirstName = v[0]
+			person.LastName = v[1]
+		} else {
+			person.FirstName = v[1]
+			person.LastName = v[0]
+		}
+	} else if len(v) == 3 {
+		if invert {
+			person.FirstName = v[0]
+			person.MiddleName = v[1]
+			person.LastName = v[2]
+		} else {
+			person.FirstName = v[1]
+			person.MiddleName = v[2]
+			person.LastName = v[0]
+		}
+	} else if l := len(v); l > 3 {
+		// several middle names, as in "Джон Рональд Руэл Толкин"
+		if invert {
+			person.FirstName = v[0]
+			person.MiddleName = strings.Join(v[1:l-1], " ")
+			person.LastName = v[l-1]
+		} else {
+			person.FirstName = v[1]
+			person.MiddleName = strings.Join(v[2:], " ")
+			person.LastName = v[0]
+		}
+	}
+	/* fmt.Printf("person: %v\n", person) */
+	return person
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NormalizeIsbn removes hyphens and spaces and upper-cases the check digit.
+func NormalizeIsbn(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "ISBN")
+	s = strings.TrimPrefix(s, ":")
+	s = strings.ReplaceAll(s, "-", "")
+	s = strings.ReplaceAll(s, " ", "")
+	return strings.ToUpper(s)
+}
+
+// IsIsbn reports whether s is a valid ISBN-10 or ISBN-13, with or without
+// hyphens.
+func IsIsbn(s string) bool {
+	return Isbn13(s) != ""
+}
+
+// Isbn13 returns the normalized ISBN-13 form of s, converting ISBN-10 if
+// needed, or "" if s is not a valid ISBN.
+func Isbn13(s string) string {
+	s = NormalizeIsbn(s)
+	switch len(s) {
+	case 10:
+		sum := 0
+		for i, r := range s {
+			d := int(r - '0')
+			if r == 'X' && i == 9 {
+				d = 10
+			} else if r < '0' || r > '9' {
+				return ""
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return ""
+		}
+		return isbn13CheckDigit("978" + s[:9])
+	case 13:
+		for _, r := range s {
+			if r < '0' || r > '9' {
+				return ""
+			}
+		}
+		if isbn13CheckDigit(s[:12]) != s {
+			return ""
+		}
+		return s
+	}
+	return ""
+}
+
+func isbn13CheckDigit(s string) string {
+	sum := 0
+	for i, r := range s {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return s + string(rune('0'+(10-sum%10)%10))
+}
+
+var isbnCandidateRe = regexp.MustCompile(`[0-9][0-9\- ]{8,15}[0-9Xx]`)
+
+// Isbns returns the valid ISBNs listed in the book in ISBN-13 form.
+// Stores often list several ISBNs for a book, separated by commas.
+func (book *Book) Isbns() []string {
+	var isbns []string
+	for _, s := range isbnCandidateRe.FindAllString(book.Isbn, -1) {
+		if isbn := Isbn13(s); isbn != "" {
+			isbns = append(isbns, isbn)
+		}
+	}
+	return isbns
+}
+
+// HasIsbn reports whether one of the ISBNs listed in the book matches isbn.
+func (book *Book) HasIsbn(isbn string) bool {
+	isbn = Isbn13(isbn)
+	if isbn == "" {
+		return false
+	}
+	for _, s := range book.Isbns() {
+		if s == isbn {
+			return true
+		}
+	}
+	return false
+}
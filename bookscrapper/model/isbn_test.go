@@ -0,0 +1,51 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsbn13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+	}{
+		{"9785389016866", "9785389016866"},
+		{"978-5-389-01686-6", "9785389016866"},
+		{"ISBN 978 5 389 01686 6", "9785389016866"},
+		{"ISBN:5-389-01686-6", "9785389016866"},
+		{"5389016866", "9785389016866"},
+		{"0-8044-2957-X", "9780804429573"},
+		{"080442957x", "9780804429573"},
+		{"5-17-118366-X", "9785171183660"},
+		// wrong check digits
+		{"978-5-389-01686-5", ""},
+		{"5-389-01686-7", ""},
+		// X stands for 10 only as the check digit
+		{"08044295X7", ""},
+		{"978-5-389-01686-X", ""},
+		{"978-5-389-01686", ""},
+		{"", ""},
+		{"Мастер и Маргарита", ""},
+	}
+	for _, test := range tests {
+		if got := Isbn13(test.isbn); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.isbn, got, test.want)
+		}
+		if got := IsIsbn(test.isbn); got != (test.want != "") {
+			t.Errorf("%q: IsIsbn is %v", test.isbn, got)
+		}
+	}
+}
+
+func TestIsbns(t *testing.T) {
+	book := NewBook()
+	book.Isbn = "978-5-389-01686-6, 5-17-118366-X; 978-5-00-000000-1"
+	want := []string{"9785389016866", "9785171183660"}
+	if got := book.Isbns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !book.HasIsbn("5389016866") || book.HasIsbn("9785000000001") {
+		t.Errorf("HasIsbn does not match Isbns")
+	}
+}
@@ -0,0 +1,215 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Note is a book note read back from markdown: the scraped book and what
+// the reader wrote about it.
+type Note struct {
+	Book    model.Book
+	Created string
+	Status  string
+	Rate    string
+	Review  string
+	// Sections holds the text of every other "## " section by title.
+	Sections map[string]string
+}
+
+var wikilinkRe = regexp.MustCompile(`\[\[([^\]|]*)(?:\|([^\]]*))?\]\]`)
+var posterRe = regexp.MustCompile(`<img [^>]*src="([^"]*)"`)
+
+// ParseNote reads a note in the format SaveMarkdown writes with the default
+// template.
+func ParseNote(text string) (Note, error) {
+	note := Note{Book: model.NewBook(), Sections: make(map[string]string)}
+	book := &note.Book
+	doc := parseNoteDoc(text)
+
+	for _, line := range doc.Front {
+		if k, v, ok := strings.Cut(line, ":"); ok && k == "created" {
+			note.Created = strings.TrimSpace(v)
+		}
+	}
+
+	for _, line := range doc.Head {
+		if strings.HasPrefix(line, "# ") {
+			book.Name = strings.TrimSpace(line[2:])
+			continue
+		}
+		if m := posterRe.FindStringSubmatch(line); m != nil {
+			book.PosterUrl = m[1]
+			continue
+		}
+		key, value, ok := headField(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "original name":
+			book.InitName = value
+		case "year":
+			book.Year = strings.TrimPrefix(value, "#y")
+		case "first published":
+			book.FirstYear = strings.TrimPrefix(value, "#y")
+		case "type":
+			book.Type = strings.TrimPrefix(value, "#")
+		case "status":
+			note.Status = strings.TrimPrefix(value, "#")
+		case "rate":
+			note.Rate = value
+		case "genres":
+			parseWikilinkMap(value, book.Genres)
+		case "tags":
+			parseWikilinkMap(value, book.Tags)
+		case "author":
+			book.Authors = parsePersons(value)
+		case "painter":
+			book.Painters = parsePersons(value)
+		case "editor":
+			book.Editors = parsePersons(value)
+		case "translators":
+			book.Translators = parsePersons(value)
+		case "publisher":
+			book.Publisher = firstWikilink(value)
+		case "series":
+			book.Series = firstWikilink(value)
+		case "cycle":
+			book.Cycle = firstWikilink(value)
+			if _, number, ok := strings.Cut(value, "]],"); ok {
+				book.CycleNumber = strings.TrimSpace(number)
+			}
+		case "awards":
+			for _, m := range wikilinkRe.FindAllStringSubmatch(value, -1) {
+				book.Awards = append(book.Awards, m[1])
+			}
+		case "country":
+			for _, m := range wikilinkRe.FindAllStringSubmatch(value, -1) {
+				book.Countries = append(book.Countries, m[1])
+			}
+		case "isbn":
+			book.Isbn = value
+		case "ratings":
+			for _, item := range strings.Split(value, ",") {
+				if source, rating, ok := strings.Cut(strings.TrimSpace(item), " "); ok {
+					book.SetRating(source, rating)
+				}
+			}
+		case "[labirint]":
+			book.LabirintUrl = value
+		case "[goodreads]":
+			book.GoodreadsUrl = value
+		case "[flibusta]":
+			book.FlibustaUrl = value
+		case "[litres]":
+			book.LitresUrl = value
+		case "[livelib]":
+			book.LivelibUrl = value
+		case "[openlibrary]":
+			book.OpenLibraryUrl = value
+		case "[googlebooks]":
+			book.GoogleBooksUrl = value
+		case "[fantlab]":
+			book.FantlabUrl = value
+		case "[chitaigorod]":
+			book.ChitaiGorodUrl = value
+		case "[ozon]":
+			book.OzonUrl = value
+		case "[generic]":
+			book.OtherUrl = value
+		}
+	}
+
+	for _, s := range doc.Sections {
+		text := strings.TrimSpace(strings.Join(s.Lines, "\n"))
+		switch s.Title {
+		case "Summary":
+			book.Summary = text
+		case "Review":
+			note.Review = text
+		default:
+			note.Sections[s.Title] = text
+		}
+	}
+
+	if book.Name == "" {
+		return note, fmt.Errorf("not a book note: no title")
+	}
+	return note, nil
+}
+
+// LoadNote reads the note file at path.
+func LoadNote(path string) (Note, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Note{}, err
+	}
+	note, err := ParseNote(string(content))
+	if err != nil {
+		return note, fmt.Errorf("%s: %w", path, err)
+	}
+	note.Book.FileName = filepath.Base(path)
+	return note, nil
+}
+
+func firstWikilink(text string) string {
+	if m := wikilinkRe.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// parseWikilinkMap is the reverse of WikilinkMap: "[[trans|genre]]" maps
+// genre to trans and "[[genre]]" maps it to nothing.
+func parseWikilinkMap(text string, lst map[string]string) {
+	for _, m := range wikilinkRe.FindAllStringSubmatch(text, -1) {
+		if m[2] != "" {
+			lst[m[2]] = m[1]
+		} else {
+			lst[m[1]] = ""
+		}
+	}
+}
+
+func parsePersons(text string) []model.Person {
+	var persons []model.Person
+	for _, m := range wikilinkRe.FindAllStringSubmatch(text, -1) {
+		persons = append(persons, parsePrintedName(m[1]))
+	}
+	return persons
+}
+
+// parsePrintedName is the reverse of Person.PrintName: it reads either
+// "Last, First" or "Last I.O.".
+func parsePrintedName(name string) model.Person {
+	var person model.Person
+	if last, first, ok := strings.Cut(name, ", "); ok {
+		person.LastName = strings.TrimSpace(last)
+		person.FirstName = strings.TrimSpace(first)
+		return person
+	}
+	name = strings.TrimSpace(name)
+	if pos := strings.LastIndex(name, " "); pos > 0 && isInitials(name[pos+1:]) {
+		person.LastName = name[:pos]
+		person.Initials = name[pos+1:]
+	} else {
+		person.LastName = name
+	}
+	return person
+}
+
+func isInitials(s string) bool {
+	if s == "" || !unicode.IsUpper(model.FirstRune(s)) {
+		return false
+	}
+	count := utf8.RuneCountInString(s)
+	return count == 1 || strings.HasSuffix(s, ".") && count <= 4
+}
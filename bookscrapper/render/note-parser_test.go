@@ -0,0 +1,67 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// TestParseNote reads back a note written with the default template.
+func TestParseNote(t *testing.T) {
+	book := model.NewBook()
+	book.Name = "Хоббит, или Туда и обратно"
+	book.InitName = "The Hobbit, or There and Back Again"
+	book.PosterUrl = "https://example.com/hobbit.jpg"
+	book.Year = "2015"
+	book.FirstYear = "1937"
+	book.Genres["фэнтези"] = "fantasy"
+	book.Tags["драконы"] = ""
+	book.Authors = []model.Person{{FirstName: "Джон", LastName: "Толкин"}}
+	book.Translators = []model.Person{{FirstName: "Наталья", LastName: "Рахманова"}}
+	book.Publisher = "АСТ"
+	book.Series = "Эксклюзивная классика"
+	book.Cycle = "Легендариум Средиземья"
+	book.CycleNumber = "2"
+	book.Awards = []string{"Ретро-Хьюго"}
+	book.Countries = []string{"Великобритания"}
+	book.Isbn = "978-5-17-085929-8"
+	book.Summary = "Бильбо отправляется в поход."
+	book.LabirintUrl = "https://www.labirint.ru/books/1/"
+	book.FantlabUrl = "https://fantlab.ru/work2"
+	book.OtherUrl = "https://books.example.com/hobbit"
+	book.SetRating("labirint", "9.1")
+
+	tmpl, err := LoadTemplate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text bytes.Buffer
+	if err := RenderNote(&text, tmpl, &book, "/Lib/"); err != nil {
+		t.Fatal(err)
+	}
+	note, err := ParseNote(text.String() + "Понравилось.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := json.MarshalIndent(note.Book, "", "  ")
+	want, _ := json.MarshalIndent(book, "", "  ")
+	if !bytes.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if note.Status != "inbox" || note.Created == "" {
+		t.Errorf("got status %q, created %q", note.Status, note.Created)
+	}
+	if !strings.Contains(note.Sections["Links"], "Понравилось.") {
+		t.Errorf("got sections %q", note.Sections)
+	}
+}
+
+func TestParseNoteNotABook(t *testing.T) {
+	if _, err := ParseNote("just some text\n## Links\n"); err == nil {
+		t.Error("got no error for a note without a title")
+	}
+}
@@ -0,0 +1,146 @@
+// Package render writes book notes in markdown from templates, reads them
+// back and merges fresh metadata into notes that already exist.
+package render
+
+import (
+	_ "embed"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+//go:embed templates/note.md
+var defaultNoteTemplate string
+
+// NoteData is what note templates are executed with: the book itself plus
+// the values that depend on when and where the note is written.
+type NoteData struct {
+	model.Book
+	Created time.Time
+	Folder  string
+}
+
+var noteFuncs = template.FuncMap{
+	"wikilink": Wikilink,
+	"persons":  WikilinkPersons,
+	"tags":     WikilinkMap,
+	"list":     WikilinkList,
+	"yeartag":  YearTag,
+	"ratings":  FormatRatings,
+}
+
+// Wikilink formats an Obsidian link, optionally with a display alias.
+func Wikilink(name string, alias ...string) string {
+	if len(alias) > 0 && alias[0] != "" && alias[0] != name {
+		return "[[" + name + "|" + alias[0] + "]]"
+	}
+	return "[[" + name + "]]"
+}
+
+func WikilinkList(lst []string) string {
+	var links []string
+	for _, str := range lst {
+		links = append(links, Wikilink(str))
+	}
+	return strings.Join(links, ", ")
+}
+
+func WikilinkPersons(lst []model.Person) string {
+	var links []string
+	for _, person := range lst {
+		links = append(links, Wikilink(person.PrintName()))
+	}
+	return strings.Join(links, ", ")
+}
+
+// WikilinkMap links genres or tags: every key links to its translation and
+// is shown under its original name. Keys translated to "#" or not
+// translated at all link to themselves.
+func WikilinkMap(lst map[string]string) string {
+	var keys []string
+	for k := range lst {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var links []string
+	for _, k := range keys {
+		v := lst[k]
+		if v == "" || v == "#" {
+			links = append(links, Wikilink(k))
+		} else {
+			links = append(links, Wikilink(v, k))
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// FormatRatings lists ratings by source, e.g. "goodreads 4.21, livelib 4.3".
+func FormatRatings(ratings map[string]string) string {
+	var sources []string
+	for source := range ratings {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var list []string
+	for _, source := range sources {
+		list = append(list, source+" "+ratings[source])
+	}
+	return strings.Join(list, ", ")
+}
+
+func YearTag(year string) string {
+	return "#y" + year
+}
+
+// LoadTemplate parses a note template. The name may be a file path or the
+// name of a template in dir; an empty name selects the built-in template.
+func LoadTemplate(name string, dir string) (*template.Template, error) {
+	text := defaultNoteTemplate
+	if name != "" {
+		content, err := os.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) && !strings.ContainsRune(name, filepath.Separator) {
+			content, err = os.ReadFile(filepath.Join(dir, name+".md"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		text = string(content)
+	}
+	return template.New("note").Funcs(noteFuncs).Parse(text)
+}
+
+// RenderNote writes the note for the book. Library folders are linked
+// under root.
+func RenderNote(w io.Writer, tmpl *template.Template, book *model.Book, root string) error {
+	return tmpl.Execute(w, NoteData{
+		Book:    *book,
+		Created: time.Now(),
+		Folder:  LibraryFolder(book, root),
+	})
+}
+
+// LibraryFolder returns the folder the book files are kept in, e.g.
+// "/Lib/ru/С/Сото, Эрнандо де".
+func LibraryFolder(book *model.Book, root string) string {
+	folder := root
+	author := book.GetPrintAuthor()
+	if model.IsEnglish(author) {
+		folder += "en/"
+	} else {
+		folder += "ru/"
+	}
+	if author != "" {
+		folder += string(model.FirstRune(author)) + "/" + author
+	}
+	return folder
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// TestDefaultTemplate checks that the built-in template writes the notes
+// SaveMarkdown wrote before templates, kept in testdata/baseline-note.md,
+// for a book with only the fields SaveMarkdown knew.
+func TestDefaultTemplate(t *testing.T) {
+	book := model.NewBook()
+	book.Name = "The Hobbit, or There and Back Again"
+	book.InitName = "The Hobbit"
+	book.PosterUrl = "https://example.com/hobbit.jpg"
+	book.Year = "1937"
+	book.Genres["фэнтези"] = "fantasy"
+	book.Tags["приключения"] = ""
+	book.Series = "Эксклюзивная классика"
+	book.Authors = []model.Person{{FirstName: "John", LastName: "Tolkien"}}
+	book.Painters = []model.Person{{FirstName: "Alan", LastName: "Lee"}}
+	book.Editors = []model.Person{{LastName: "Иванова", Initials: "Е.А."}}
+	book.Translators = []model.Person{{FirstName: "Наталья", LastName: "Рахманова"}}
+	book.Countries = []string{"Великобритания"}
+	book.Publisher = "АСТ"
+	book.Isbn = "978-5-17-085929-0"
+	book.Summary = "Bilbo goes on an adventure."
+	book.LabirintUrl = "https://www.labirint.ru/books/1/"
+	book.GoodreadsUrl = "https://www.goodreads.com/book/show/5907"
+	book.FlibustaUrl = "https://flibusta.is/b/1"
+	book.LitresUrl = "https://www.litres.ru/book/1/"
+	book.LivelibUrl = "https://www.livelib.ru/book/1"
+
+	tmpl, err := LoadTemplate("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	err = tmpl.Execute(&got, NoteData{
+		Book:    book,
+		Created: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Folder:  LibraryFolder(&book, "/Lib/"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "baseline-note.md")
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("note differs from %s:\ngot:\n%s\nwant:\n%s", path, got.Bytes(), want)
+	}
+}
@@ -0,0 +1,287 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+)
+
+// userFields are the note fields only the reader fills in; updating a note
+// never touches them.
+var userFields = map[string]bool{
+	"status": true,
+	"rate":   true,
+}
+
+// scrapedSections are the note sections regenerated from the scraped book.
+// Every other section is written by the reader and kept as is.
+var scrapedSections = map[string]bool{
+	"Summary": true,
+}
+
+type noteSection struct {
+	Title string
+	Lines []string
+}
+
+// noteDoc is a note split into the parts updates treat differently: the
+// frontmatter, the head with the metadata fields and the "## " sections.
+type noteDoc struct {
+	Front    []string
+	Head     []string
+	Sections []noteSection
+}
+
+func parseNoteDoc(text string) noteDoc {
+	var doc noteDoc
+	lines := strings.Split(text, "\n")
+
+	if len(lines) > 0 && lines[0] == "---" {
+		for i := 1; i < len(lines); i++ {
+			if lines[i] == "---" {
+				doc.Front = lines[1:i]
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "## ") {
+			doc.Sections = append(doc.Sections, noteSection{Title: strings.TrimSpace(line[3:])})
+		} else if len(doc.Sections) > 0 {
+			s := &doc.Sections[len(doc.Sections)-1]
+			s.Lines = append(s.Lines, line)
+		} else {
+			doc.Head = append(doc.Head, line)
+		}
+	}
+	return doc
+}
+
+func (doc noteDoc) String() string {
+	var lines []string
+	if doc.Front != nil {
+		lines = append(lines, "---")
+		lines = append(lines, doc.Front...)
+		lines = append(lines, "---")
+	}
+	lines = append(lines, doc.Head...)
+	for _, s := range doc.Sections {
+		lines = append(lines, "## "+s.Title)
+		lines = append(lines, s.Lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (doc noteDoc) section(title string) *noteSection {
+	for i := range doc.Sections {
+		if doc.Sections[i].Title == title {
+			return &doc.Sections[i]
+		}
+	}
+	return nil
+}
+
+var fieldRe = regexp.MustCompile(`^\*\*([^*\[\]]+):\*\*(.*)$`)
+var linkRe = regexp.MustCompile(`^\*\*\[([^\]]+)\]\((.*)\)\*\*$`)
+
+// headField returns the key and value of a metadata line such as
+// "**year:** #y2001" or "**[labirint](https://...)**".
+func headField(line string) (key string, value string, ok bool) {
+	if m := fieldRe.FindStringSubmatch(line); m != nil {
+		return m[1], strings.TrimSpace(m[2]), true
+	}
+	if m := linkRe.FindStringSubmatch(line); m != nil {
+		return "[" + m[1] + "]", strings.TrimSpace(m[2]), true
+	}
+	return "", "", false
+}
+
+// templateLine tells whether a head line that is not a field comes from
+// the template, like the title, the poster or the bold command lines.
+func templateLine(line string) bool {
+	for _, prefix := range []string{"# ", "<div", "![[", "---"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(line, "**") && strings.HasSuffix(line, "**")
+}
+
+func frontKey(line string) string {
+	k, _, ok := strings.Cut(line, ":")
+	if !ok || strings.HasPrefix(line, " ") {
+		return ""
+	}
+	return k
+}
+
+// MergeNote refreshes the scraped parts of an existing note with a freshly
+// rendered one. User fields, user sections and anything the template does
+// not know about, such as text written under the title, are kept; scraped
+// values that came back empty do not erase what the note already has.
+func MergeNote(old string, fresh string) string {
+	o := parseNoteDoc(old)
+	f := parseNoteDoc(fresh)
+	var merged noteDoc
+
+	merged.Front = append(merged.Front, o.Front...)
+	for _, line := range f.Front {
+		key := frontKey(line)
+		if key == "" || key == "created" {
+			continue
+		}
+		found := false
+		for i, l := range merged.Front {
+			if frontKey(l) == key {
+				merged.Front[i] = line
+				found = true
+			}
+		}
+		if !found {
+			merged.Front = append(merged.Front, line)
+		}
+	}
+	if o.Front == nil && f.Front == nil {
+		merged.Front = nil
+	}
+
+	oldFields := make(map[string]string)
+	for _, line := range o.Head {
+		if key, _, ok := headField(line); ok {
+			oldFields[key] = line
+		}
+	}
+	freshKeys := make(map[string]bool)
+	for _, line := range f.Head {
+		key, value, ok := headField(line)
+		if ok {
+			freshKeys[key] = true
+			if prev, found := oldFields[key]; found && (userFields[key] || value == "") {
+				line = prev
+			}
+		}
+		merged.Head = append(merged.Head, line)
+	}
+
+	// the old head lines the template does not produce, fields it no longer
+	// has and text the reader wrote, stay after the line they followed in
+	// the old note
+	after := -1
+	for i, l := range merged.Head {
+		if _, _, ok := headField(l); ok {
+			after = i - 1
+			break
+		}
+	}
+	kept := false
+	for _, line := range o.Head {
+		key, _, ok := headField(line)
+		switch {
+		case ok && freshKeys[key]:
+			for i, l := range merged.Head {
+				if k, _, ok := headField(l); ok && k == key {
+					after = i
+				}
+			}
+			kept = false
+			continue
+		case ok:
+		case after+1 < len(merged.Head) && merged.Head[after+1] == line:
+			after++
+			kept = false
+			continue
+		case templateLine(line) || (strings.TrimSpace(line) == "" && !kept):
+			// replaced by the fresh note, or spacing around it
+			continue
+		}
+		head := append([]string{}, merged.Head[:after+1]...)
+		head = append(head, line)
+		merged.Head = append(head, merged.Head[after+1:]...)
+		after++
+		kept = true
+	}
+
+	for _, s := range o.Sections {
+		if n := f.section(s.Title); n != nil && scrapedSections[s.Title] && strings.TrimSpace(strings.Join(n.Lines, "")) != "" {
+			s = *n
+		}
+		merged.Sections = append(merged.Sections, s)
+	}
+	for _, s := range f.Sections {
+		if o.section(s.Title) == nil {
+			merged.Sections = append(merged.Sections, s)
+		}
+	}
+
+	return merged.String()
+}
+
+// LineDiff compares two texts line by line and returns the changed lines
+// prefixed with "-" or "+", each hunk surrounded by a little context.
+func LineDiff(a string, b string) []string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var all []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			all = append(all, " "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			all = append(all, "-"+x[i])
+			i++
+		default:
+			all = append(all, "+"+y[j])
+			j++
+		}
+	}
+
+	const context = 2
+	var diff []string
+	shown := -1
+	for k, line := range all {
+		if line[0] == ' ' {
+			continue
+		}
+		from := k - context
+		if from <= shown {
+			from = shown + 1
+		}
+		if from < 0 {
+			from = 0
+		}
+		if from > shown+1 {
+			diff = append(diff, "...")
+		}
+		to := k + context
+		if to >= len(all) {
+			to = len(all) - 1
+		}
+		for n := from; n <= to; n++ {
+			diff = append(diff, all[n])
+		}
+		shown = to
+	}
+	return diff
+}
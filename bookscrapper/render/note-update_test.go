@@ -0,0 +1,109 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+// note joins lines into a note text.
+func note(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
+
+func TestMergeNote(t *testing.T) {
+	tests := []struct {
+		name  string
+		old   string
+		fresh string
+		want  string
+	}{
+		{
+			"scraped fields are refreshed",
+			note("# Хоббит", "**year:** #y2001", "**publisher:** [[АСТ]]"),
+			note("# Хоббит", "**year:** #y2015", "**publisher:** [[Азбука]]"),
+			note("# Хоббит", "**year:** #y2015", "**publisher:** [[Азбука]]"),
+		},
+		{
+			"user fields are kept",
+			note("# Хоббит", "**status:** #reading", "**rate:** 5"),
+			note("# Хоббит", "**status:** #inbox", "**rate:**"),
+			note("# Хоббит", "**status:** #reading", "**rate:** 5"),
+		},
+		{
+			"empty scraped values do not erase",
+			note("# Хоббит", "**isbn:** 978-5-17-085929-8", "**year:** #y2001"),
+			note("# Хоббит", "**isbn:**", "**year:** #y2015"),
+			note("# Хоббит", "**isbn:** 978-5-17-085929-8", "**year:** #y2015"),
+		},
+		{
+			"unknown fields stay after the field they followed",
+			note("# Хоббит", "**year:** #y2001", "**read with:** [[Аня]]", "**isbn:** 1"),
+			note("# Хоббит", "**year:** #y2015", "**isbn:** 2"),
+			note("# Хоббит", "**year:** #y2015", "**read with:** [[Аня]]", "**isbn:** 2"),
+		},
+		{
+			"free text under the title is kept",
+			note("", "# Хоббит", "Подарок на день рождения.", "", "Читать вслух.", "**year:** #y2001"),
+			note("", "# Хоббит", "**year:** #y2015"),
+			note("", "# Хоббит", "Подарок на день рождения.", "", "Читать вслух.", "**year:** #y2015"),
+		},
+		{
+			"free text between fields is kept",
+			note("# Хоббит", "**year:** #y2001", "см. также [[Сильмариллион]]", "**isbn:** 1", "", "---"),
+			note("# Хоббит", "**year:** #y2015", "**isbn:** 2", "", "---"),
+			note("# Хоббит", "**year:** #y2015", "см. также [[Сильмариллион]]", "**isbn:** 2", "", "---"),
+		},
+		{
+			"template lines are replaced, not kept",
+			note(`<div><img src="old.jpg"/></div>`, "# Хобит", "**year:** #y2001", `**{{shell: open "/Lib/old/"}}**`),
+			note(`<div><img src="new.jpg"/></div>`, "# Хоббит", "**year:** #y2001", `**{{shell: open "/Lib/new/"}}**`),
+			note(`<div><img src="new.jpg"/></div>`, "# Хоббит", "**year:** #y2001", `**{{shell: open "/Lib/new/"}}**`),
+		},
+		{
+			"user sections are kept and the summary refreshed",
+			note("# Хоббит", "## Summary", "old", "## Review", "Понравилось."),
+			note("# Хоббит", "## Summary", "new", "## Review", ""),
+			note("# Хоббит", "## Summary", "new", "## Review", "Понравилось."),
+		},
+		{
+			"an empty summary does not erase",
+			note("# Хоббит", "## Summary", "old"),
+			note("# Хоббит", "## Summary", ""),
+			note("# Хоббит", "## Summary", "old"),
+		},
+		{
+			"frontmatter keeps created and other keys",
+			note("---", "created: 2020-01-01 10:00", "cssclass: book", `alias: "Хобит (2001)"`, "---", "# Хоббит"),
+			note("---", "created: 2026-10-17 06:00", `alias: "Хоббит (2015)"`, "---", "# Хоббит"),
+			note("---", "created: 2020-01-01 10:00", "cssclass: book", `alias: "Хоббит (2015)"`, "---", "# Хоббит"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MergeNote(test.old, test.fresh); got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{note("a", "b"), note("a", "b"), nil},
+		{note("a", "b", "c"), note("a", "x", "c"), []string{" a", "-b", "+x", " c"}},
+		{
+			note("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			note("0", "1", "2", "3", "4", "5", "6", "7", "8"),
+			[]string{"+0", " 1", " 2", "...", " 7", " 8", "-9"},
+		},
+	}
+	for _, test := range tests {
+		got := LineDiff(test.a, test.b)
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q -> %q: got %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
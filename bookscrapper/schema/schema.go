@@ -0,0 +1,156 @@
+// Package schema is the JSON form of books printed by book -json and
+// -jsonl, for scripts and other programs to consume.
+//
+// The form is versioned: Version changes whenever a field is renamed or
+// removed or changes meaning, so consumers can refuse data they do not
+// understand. Adding a field does not change the version. Every field is
+// always present; missing values are empty strings, lists and objects.
+package schema
+
+import (
+	"github.com/jupy/book-scrapper/bookscrapper/match"
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Version is the version of the schema, found in the "schema" field of
+// every top level object.
+const Version = 1
+
+// Person is a person as the sources name them, split into parts. Name is
+// how notes print the person, e.g. "Толстой, Лев" or "Толстой Л.Н.".
+type Person struct {
+	Name       string `json:"name"`
+	FirstName  string `json:"first_name"`
+	MiddleName string `json:"middle_name"`
+	LastName   string `json:"last_name"`
+	Initials   string `json:"initials"`
+}
+
+// Book is a book as scraped and merged from the sources.
+type Book struct {
+	Schema int    `json:"schema"`
+	Type   string `json:"type"`
+	// FileName is the name of the note written for the book.
+	FileName      string `json:"file_name"`
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	PosterUrl     string `json:"poster_url"`
+	Year          string `json:"year"`
+	// FirstYear is the year the work was first published, when a source
+	// tells it apart from Year, the year of the edition.
+	FirstYear string `json:"first_year"`
+	// Genres and Tags map the names the sources use to their translations,
+	// which are empty when unknown.
+	Genres map[string]string `json:"genres"`
+	Tags   map[string]string `json:"tags"`
+	Series string            `json:"series"`
+	// Cycle is the cycle of works the book belongs to, CycleNumber its
+	// place in the cycle.
+	Cycle       string   `json:"cycle"`
+	CycleNumber string   `json:"cycle_number"`
+	Authors     []Person `json:"authors"`
+	Painters    []Person `json:"painters"`
+	Editors     []Person `json:"editors"`
+	Translators []Person `json:"translators"`
+	Countries   []string `json:"countries"`
+	Awards      []string `json:"awards"`
+	Publisher   string   `json:"publisher"`
+	// Isbn is the ISBN text as the source shows it, Isbns the valid ISBNs
+	// found in it in ISBN-13 form.
+	Isbn    string   `json:"isbn"`
+	Isbns   []string `json:"isbns"`
+	Summary string   `json:"summary"`
+	// Urls maps source names to the book's page there.
+	Urls map[string]string `json:"urls"`
+	// Ratings maps source names to the average rating there.
+	Ratings map[string]string `json:"ratings"`
+	// Provenance maps field names of model.Book to the sources their values
+	// came from, e.g. "Year": "labirint, livelib".
+	Provenance map[string]string `json:"provenance"`
+}
+
+// Edition is a search result: one edition of a book as found on one or
+// more stores.
+type Edition struct {
+	Schema int `json:"schema"`
+	// Score is the relevance to the query, from 0 to 1.
+	Score  float64  `json:"score"`
+	Stores []string `json:"stores"`
+	// Book merges what the stores know about the edition.
+	Book Book `json:"book"`
+}
+
+func FromPerson(p model.Person) Person {
+	return Person{
+		Name:       p.PrintName(),
+		FirstName:  p.FirstName,
+		MiddleName: p.MiddleName,
+		LastName:   p.LastName,
+		Initials:   p.Initials,
+	}
+}
+
+func fromPersons(persons []model.Person) []Person {
+	list := []Person{}
+	for _, p := range persons {
+		list = append(list, FromPerson(p))
+	}
+	return list
+}
+
+// FromBook converts a book to its JSON form.
+func FromBook(book *model.Book) Book {
+	urls := make(map[string]string)
+	for _, name := range book.FoundOn() {
+		urls[name] = book.SourceUrl(name)
+	}
+	return Book{
+		Schema:        Version,
+		Type:          book.Type,
+		FileName:      book.FileName,
+		Title:         book.Name,
+		OriginalTitle: book.InitName,
+		PosterUrl:     book.PosterUrl,
+		Year:          book.Year,
+		FirstYear:     book.FirstYear,
+		Genres:        copyMap(book.Genres),
+		Tags:          copyMap(book.Tags),
+		Series:        book.Series,
+		Cycle:         book.Cycle,
+		CycleNumber:   book.CycleNumber,
+		Authors:       fromPersons(book.Authors),
+		Painters:      fromPersons(book.Painters),
+		Editors:       fromPersons(book.Editors),
+		Translators:   fromPersons(book.Translators),
+		Countries:     append([]string{}, book.Countries...),
+		Awards:        append([]string{}, book.Awards...),
+		Publisher:     book.Publisher,
+		Isbn:          book.Isbn,
+		Isbns:         append([]string{}, book.Isbns()...),
+		Summary:       book.Summary,
+		Urls:          urls,
+		Ratings:       copyMap(book.Ratings),
+		Provenance:    copyMap(book.Provenance),
+	}
+}
+
+// FromEdition converts a search result to its JSON form, merging the
+// edition without asking.
+func FromEdition(edition *match.Edition, policy merge.Policy) Edition {
+	book := edition.Merge(policy, nil)
+	return Edition{
+		Schema: Version,
+		Score:  edition.Score,
+		Stores: append([]string{}, edition.Stores()...),
+		Book:   FromBook(&book),
+	}
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the JSON form of the books")
+
+// TestBookGolden keeps the JSON form of a book in testdata/book.json, so
+// that a change of the schema shows in review. After a deliberate change
+// run
+//
+//	go test -update
+//
+// and bump Version if a field was renamed or removed or changed meaning.
+func TestBookGolden(t *testing.T) {
+	book := model.NewBook()
+	book.Name = "Мастер и Маргарита"
+	book.InitName = "Мастер и Маргарита"
+	book.PosterUrl = "https://img.example.com/master.jpg"
+	book.Year = "2022"
+	book.FirstYear = "1967"
+	book.Genres["роман"] = "novel"
+	book.Tags["мистика"] = ""
+	book.Series = "Азбука-классика"
+	book.Authors = []model.Person{model.ParsePerson("Михаил Афанасьевич Булгаков", true)}
+	book.Painters = []model.Person{model.ParsePerson("Ли Алан", false)}
+	book.Translators = []model.Person{}
+	book.Countries = []string{"Россия"}
+	book.Awards = []string{"Премия"}
+	book.Publisher = "Азбука"
+	book.Isbn = "978-5-389-01686-6, 5-17-118366-X"
+	book.Summary = "Роман о дьяволе в Москве."
+	book.LabirintUrl = "https://www.labirint.ru/books/1/"
+	book.OzonUrl = "https://www.ozon.ru/product/1/"
+	book.OtherUrl = "https://books.example.com/master"
+	book.SetRating("labirint", "9,1")
+	book.Provenance = map[string]string{"Year": "labirint, ozon", "Summary": "ozon"}
+	book.InitFileName()
+
+	got, err := json.MarshalIndent(FromBook(&book), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", "book.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("book differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestEmptyBook(t *testing.T) {
+	book := model.Book{}
+	got, err := json.Marshal(FromBook(&book))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), "null") {
+		t.Errorf("missing values are not empty: %s", got)
+	}
+}
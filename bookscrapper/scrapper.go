@@ -0,0 +1,51 @@
+// Package bookscrapper looks books up on bookstores and catalogues: it
+// searches the sources, fetches book pages, tells which results are the
+// same edition and merges what the sources know about a book.
+//
+// The subpackages can be used on their own: model holds the book data,
+// sources the scrapers, merge and match combine results, render writes and
+// reads notes and translate translates genres and tags.
+package bookscrapper
+
+import (
+	"context"
+
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// Scrapper runs lookups on the sources of its registry.
+type Scrapper struct {
+	Env      *sources.Env
+	Registry *sources.Registry
+	// Policy and Resolver decide how the descriptions of several sources
+	// are merged. Resolver may be nil.
+	Policy   merge.Policy
+	Resolver merge.Resolver
+	// Fallback fetches the links no source of the registry owns. Nil
+	// leaves them unsupported.
+	Fallback sources.Source
+}
+
+// New returns a scrapper with the built-in sources enabled in their
+// default order.
+func New(env *sources.Env) *Scrapper {
+	registry := sources.NewRegistry()
+	for _, source := range sources.Builtin(env) {
+		registry.Register(source)
+	}
+	for lang, names := range sources.DefaultOrder() {
+		registry.SetOrder(lang, names)
+	}
+	return &Scrapper{
+		Env:      env,
+		Registry: registry,
+		Policy:   merge.DefaultPolicy(),
+		Fallback: sources.GenericSource{Env: env},
+	}
+}
+
+// Search searches the sources enabled for the language of the query.
+func (scrapper *Scrapper) Search(ctx context.Context, query string) <-chan sources.Result {
+	return sources.SearchAll(ctx, scrapper.Registry.Enabled(sources.QueryLanguage(query)), query)
+}
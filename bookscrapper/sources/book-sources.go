@@ -0,0 +1,31 @@
+package sources
+
+// Builtin returns the sources the scrapper comes with, see BuiltinSites.
+func Builtin(env *Env) []Source {
+	var sources []Source
+	for _, site := range BuiltinSites() {
+		source, err := NewSiteSource(site, env)
+		if err != nil {
+			panic(err)
+		}
+		sources = append(sources, source)
+	}
+	return append(sources, OpenLibrarySource{Env: env}, GoogleBooksSource{Env: env}, FantlabSource{Env: env})
+}
+
+// DefaultOrder is the order the built-in sources are tried in for every
+// query language. Google Books finds mostly translations for Russian
+// titles, so Russian books only get it when enriched by ISBN.
+func DefaultOrder() map[string][]string {
+	return map[string][]string{
+		"ru": {"labirint", "livelib", "litres", "chitaigorod", "ozon", "fantlab"},
+		"en": {"goodreads", "openlibrary", "googlebooks"},
+	}
+}
+
+func sourceError(source string, link string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &SourceError{Source: source, Url: link, Err: err}
+}
@@ -0,0 +1,282 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache keeps fetched pages and search responses on disk. Bodies are
+// stored once per content under blobs/, named by their SHA-256; entries
+// under entries/ map a request to its response and body.
+type Cache struct {
+	Dir string
+	// TTL sets how long responses from a domain and its subdomains stay
+	// fresh. Other domains use DefaultTTL.
+	TTL        map[string]time.Duration
+	DefaultTTL time.Duration
+	// Offline serves every request from the cache, however old, and fails
+	// the requests for pages that are not in it.
+	Offline bool
+}
+
+// CacheEntry describes a cached response.
+type CacheEntry struct {
+	// Key names the entry file: the SHA-256 of the request without its
+	// API key.
+	Key string `json:"key"`
+	// Url is the requested URL with API keys left out.
+	Url     string      `json:"url"`
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    string      `json:"body"`
+	Size    int         `json:"size"`
+	Fetched time.Time   `json:"fetched"`
+}
+
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{
+		Dir:        dir,
+		TTL:        make(map[string]time.Duration),
+		DefaultTTL: ttl,
+	}
+}
+
+// Host returns the host the entry was fetched from.
+func (entry *CacheEntry) Host() string {
+	u, err := url.Parse(entry.Url)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func (cache *Cache) ttl(host string) time.Duration {
+	for domain, ttl := range cache.TTL {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return ttl
+		}
+	}
+	return cache.DefaultTTL
+}
+
+// Expired reports whether the entry is too old to be served online.
+func (cache *Cache) Expired(entry *CacheEntry) bool {
+	return time.Since(entry.Fetched) > cache.ttl(entry.Host())
+}
+
+// Transport returns a round tripper answering from the cache and passing
+// the other requests to base, caching their successful responses.
+func (cache *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	return cacheTransport{cache: cache, base: base}
+}
+
+type cacheTransport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+func (t cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if t.cache.Offline {
+			return nil, fmt.Errorf("%w: %s %s", ErrNotCached, req.Method, req.URL)
+		}
+		return t.base.RoundTrip(req)
+	}
+
+	key := requestKey(req)
+	if entry, err := t.cache.load(key); err == nil {
+		if t.cache.Offline || !t.cache.Expired(&entry) {
+			if body, err := os.ReadFile(t.cache.blobPath(entry.Body)); err == nil {
+				return entry.response(req, body), nil
+			}
+		}
+	}
+	if t.cache.Offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, redactUrl(req.URL))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if isCaptcha(body) {
+		return resp, nil
+	}
+	t.cache.store(CacheEntry{
+		Key:     key,
+		Url:     redactUrl(req.URL),
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Size:    len(body),
+		Fetched: time.Now(),
+	}, body)
+	return resp, nil
+}
+
+func (entry *CacheEntry) response(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		StatusCode:    entry.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// requestKey leaves API keys out too, so that a new key keeps the cached
+// answers.
+func requestKey(req *http.Request) string {
+	return hash([]byte(req.Method + " " + redactUrl(req.URL)))
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactUrl leaves API keys out of URLs written to the cache.
+func redactUrl(u *url.URL) string {
+	query := u.Query()
+	if query.Get("key") == "" {
+		return u.String()
+	}
+	query.Set("key", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func (cache *Cache) entryPath(key string) string {
+	return filepath.Join(cache.Dir, "entries", key+".json")
+}
+
+func (cache *Cache) blobPath(sum string) string {
+	return filepath.Join(cache.Dir, "blobs", sum)
+}
+
+func (cache *Cache) load(key string) (CacheEntry, error) {
+	var entry CacheEntry
+	content, err := os.ReadFile(cache.entryPath(key))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(content, &entry)
+	return entry, err
+}
+
+// store writes the body and then the entry, so that an entry never points
+// to a missing body. Failing to cache is not an error for the request.
+func (cache *Cache) store(entry CacheEntry, body []byte) {
+	entry.Body = hash(body)
+	if _, err := os.Stat(cache.blobPath(entry.Body)); err != nil {
+		if writeFile(cache.blobPath(entry.Body), body) != nil {
+			return
+		}
+	}
+	content, err := json.MarshalIndent(entry, "", " ")
+	if err != nil {
+		return
+	}
+	writeFile(cache.entryPath(entry.Key), content)
+}
+
+// writeFile writes the file through a temporary file, so that readers
+// never see it half written.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Entries returns the cached responses, oldest first.
+func (cache *Cache) Entries() ([]CacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(cache.Dir, "entries", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, path := range paths {
+		entry, err := cache.load(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Fetched.Before(entries[j].Fetched)
+	})
+	return entries, nil
+}
+
+// Purge removes the entries for which remove returns true, or all of them
+// if remove is nil, and the bodies no entry refers to any more. It returns
+// the number of entries removed.
+func (cache *Cache) Purge(remove func(entry *CacheEntry) bool) (int, error) {
+	entries, err := cache.Entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	used := make(map[string]bool)
+	for i := range entries {
+		entry := &entries[i]
+		if remove == nil || remove(entry) {
+			err := os.Remove(cache.entryPath(entry.Key))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return removed, err
+			}
+			removed++
+		} else {
+			used[entry.Body] = true
+		}
+	}
+
+	blobs, err := filepath.Glob(filepath.Join(cache.Dir, "blobs", "*"))
+	if err != nil {
+		return removed, err
+	}
+	for _, blob := range blobs {
+		if !used[filepath.Base(blob)] {
+			os.Remove(blob)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,157 @@
+package sources
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cacheServer counts the requests for each path and answers them with the
+// path, a captcha page for /captcha and 404 for /missing.
+func cacheServer(t *testing.T) (*httptest.Server, map[string]int) {
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		switch r.URL.Path {
+		case "/captcha":
+			io.WriteString(w, `<div class="smart-captcha"></div>`)
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			io.WriteString(w, "page "+r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, hits
+}
+
+func cacheGet(t *testing.T, cache *Cache, link string) (string, error) {
+	t.Helper()
+	client := &http.Client{Transport: cache.Transport(http.DefaultTransport)}
+	resp, err := client.Get(link)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestCacheTTL(t *testing.T) {
+	server, hits := cacheServer(t)
+	cache := NewCache(t.TempDir(), time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if body, err := cacheGet(t, cache, server.URL+"/book"); err != nil || body != "page /book" {
+			t.Fatalf("got %q, %v", body, err)
+		}
+	}
+	if hits["/book"] != 1 {
+		t.Errorf("fresh page fetched %d times", hits["/book"])
+	}
+
+	// a domain of its own TTL overrides the default
+	cache.TTL["127.0.0.1"] = 0
+	cacheGet(t, cache, server.URL+"/book")
+	if hits["/book"] != 2 {
+		t.Errorf("expired page fetched %d times", hits["/book"])
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	server, hits := cacheServer(t)
+	cache := NewCache(t.TempDir(), 0)
+	cacheGet(t, cache, server.URL+"/book")
+
+	cache.Offline = true
+	if body, err := cacheGet(t, cache, server.URL+"/book"); err != nil || body != "page /book" {
+		t.Errorf("expired page offline: got %q, %v", body, err)
+	}
+	if _, err := cacheGet(t, cache, server.URL+"/other"); !errors.Is(err, ErrNotCached) {
+		t.Errorf("page not in the cache: got %v", err)
+	}
+	if hits["/book"] != 1 || hits["/other"] != 0 {
+		t.Errorf("requests went out offline: %v", hits)
+	}
+}
+
+func TestCacheRedactsApiKeys(t *testing.T) {
+	server, hits := cacheServer(t)
+	dir := t.TempDir()
+	cache := NewCache(dir, time.Hour)
+
+	cacheGet(t, cache, server.URL+"/search?q=dune&key=secret")
+	// a new key is served the same answer
+	cacheGet(t, cache, server.URL+"/search?q=dune&key=rotated")
+	if hits["/search"] != 1 {
+		t.Errorf("fetched %d times", hits["/search"])
+	}
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if content, _ := os.ReadFile(path); strings.Contains(string(content), "secret") {
+				t.Errorf("%s holds the API key", path)
+			}
+		}
+		return nil
+	})
+	entries, err := cache.Entries()
+	if err != nil || len(entries) != 1 || !strings.Contains(entries[0].Url, "key=REDACTED") {
+		t.Errorf("got %+v, %v", entries, err)
+	}
+}
+
+func TestCacheSkipsFailures(t *testing.T) {
+	server, hits := cacheServer(t)
+	cache := NewCache(t.TempDir(), time.Hour)
+
+	for i := 0; i < 2; i++ {
+		cacheGet(t, cache, server.URL+"/missing")
+		cacheGet(t, cache, server.URL+"/captcha")
+	}
+	if hits["/missing"] != 2 || hits["/captcha"] != 2 {
+		t.Errorf("failures were cached: %v", hits)
+	}
+	if entries, _ := cache.Entries(); len(entries) != 0 {
+		t.Errorf("got %d entries", len(entries))
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	server, _ := cacheServer(t)
+	dir := t.TempDir()
+	cache := NewCache(dir, time.Hour)
+	// both queries get the same body, stored once
+	cacheGet(t, cache, server.URL+"/book?a=1")
+	cacheGet(t, cache, server.URL+"/book?a=2")
+	cacheGet(t, cache, server.URL+"/other")
+
+	blobs := func() int {
+		paths, _ := filepath.Glob(filepath.Join(dir, "blobs", "*"))
+		return len(paths)
+	}
+	if blobs() != 2 {
+		t.Fatalf("got %d blobs", blobs())
+	}
+
+	n, err := cache.Purge(func(entry *CacheEntry) bool {
+		return strings.HasSuffix(entry.Url, "a=1") || strings.HasSuffix(entry.Url, "/other")
+	})
+	if err != nil || n != 2 {
+		t.Errorf("removed %d, %v", n, err)
+	}
+	// the body of /book is still used by the entry left
+	if entries, _ := cache.Entries(); len(entries) != 1 || blobs() != 1 {
+		t.Errorf("got %d entries and %d blobs", len(entries), blobs())
+	}
+
+	if n, err := cache.Purge(nil); err != nil || n != 1 || blobs() != 0 {
+		t.Errorf("removed %d, %v, %d blobs left", n, err, blobs())
+	}
+}
@@ -0,0 +1,127 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Translator translates genres and tags, see the translate package.
+type Translator interface {
+	Translate(text string) (string, error)
+}
+
+// Env is what the sources share: how pages are searched for and fetched,
+// and how scraped genres and tags are translated.
+type Env struct {
+	Searcher Searcher
+	// Limiter spaces out requests to the same site; nil means no limit.
+	Limiter *DomainLimiter
+	// Translator may be nil to keep genres and tags untranslated.
+	Translator Translator
+	// Transport sends the requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	// Mirrors maps hosts to the base URLs their requests are sent to
+	// instead, e.g. a local server with saved pages.
+	Mirrors map[string]string
+	// Cache answers the requests it holds without going to the site; nil
+	// means nothing is cached.
+	Cache *Cache
+	// Logf reports problems that do not stop a lookup, such as tags that
+	// cannot be translated. It may be nil.
+	Logf func(format string, args ...interface{})
+}
+
+// NewEnv returns an environment searching with no backend and waiting a
+// second between requests to the same site.
+func NewEnv() *Env {
+	return &Env{
+		Searcher: FallbackSearcher{},
+		Limiter:  NewDomainLimiter(1 * time.Second),
+	}
+}
+
+func (env *Env) logf(format string, args ...interface{}) {
+	if env.Logf != nil {
+		env.Logf(format, args...)
+	}
+}
+
+// RoundTripper returns the transport for requests made within the context:
+// answered from the cache when possible, rate limited otherwise.
+func (env *Env) RoundTripper(ctx context.Context) http.RoundTripper {
+	base := env.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if len(env.Mirrors) > 0 {
+		base = mirrorTransport{mirrors: env.Mirrors, base: base}
+	}
+	var t http.RoundTripper = limitedTransport{ctx: ctx, limiter: env.Limiter, base: base}
+	if env.Cache != nil {
+		t = env.Cache.Transport(t)
+	}
+	return t
+}
+
+// NewCollector returns a collector for the domains whose requests are rate
+// limited, cached and cancelled with the context.
+func (env *Env) NewCollector(ctx context.Context, domains ...string) *colly.Collector {
+	c := colly.NewCollector(
+		colly.AllowedDomains(domains...),
+	)
+	c.WithTransport(env.RoundTripper(ctx))
+	return c
+}
+
+// getJson fetches the link like the pages of collectors are fetched and
+// decodes the JSON it answers with into v.
+func (env *Env) getJson(ctx context.Context, link string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Transport: env.RoundTripper(ctx)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode, fmt.Errorf("%s: %s", link, resp.Status))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", link, err)
+	}
+	return nil
+}
+
+func (env *Env) translate(text string) string {
+	if env.Translator == nil {
+		return ""
+	}
+	trans, err := env.Translator.Translate(text)
+	if err != nil {
+		env.logf("can't translate: %v\n", err)
+	} else if trans == "" {
+		env.logf("can't translate: %s\n", text)
+	}
+	return trans
+}
+
+func (env *Env) appendGenre(book *model.Book, genre string) {
+	genre = strings.ToLower(genre)
+	book.Genres[genre] = env.translate(genre)
+}
+
+func (env *Env) appendTag(book *model.Book, tag string) {
+	tag = strings.ToLower(tag)
+	book.Tags[tag] = env.translate(tag)
+}
@@ -0,0 +1,97 @@
+package sources
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gocolly/colly"
+)
+
+var (
+	// ErrNotFound means there is no such book or page.
+	ErrNotFound = errors.New("not found")
+	// ErrBlocked means the site refused to serve the page or asked for a
+	// captcha.
+	ErrBlocked = errors.New("blocked by the site")
+	// ErrQuotaExceeded is returned by searchers that ran out of requests.
+	ErrQuotaExceeded = errors.New("search quota exceeded")
+	// ErrIncomplete means the page was fetched but the parser could not
+	// find the book on it, usually because the site changed its layout.
+	ErrIncomplete = errors.New("parse incomplete")
+	// ErrNotCached is returned in offline mode for pages that are not in
+	// the cache.
+	ErrNotCached = errors.New("not in the cache")
+)
+
+// SourceError is an error that happened while a source fetched a page.
+type SourceError struct {
+	Source string
+	Url    string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return e.Source + ": " + e.Url + ": " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// captchaMarkers are found on the pages sites show instead of the content
+// when they suspect a robot.
+var captchaMarkers = [][]byte{
+	[]byte("showcaptcha"),
+	[]byte("smart-captcha"),
+	[]byte("g-recaptcha"),
+	[]byte("cf-challenge"),
+	[]byte("Подтвердите, что вы не робот"),
+}
+
+func isCaptcha(body []byte) bool {
+	for _, marker := range captchaMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// visitPage visits the page with the collector and tells why it failed,
+// if it did.
+func visitPage(c *colly.Collector, link string) error {
+	var pageErr error
+	c.OnResponse(func(r *colly.Response) {
+		if isCaptcha(r.Body) {
+			pageErr = fmt.Errorf("%w: captcha", ErrBlocked)
+		}
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		pageErr = responseError(r, err)
+	})
+	if err := c.Visit(link); err != nil && pageErr == nil {
+		pageErr = err
+	}
+	return pageErr
+}
+
+func responseError(r *colly.Response, err error) error {
+	if r != nil {
+		return statusError(r.StatusCode, err)
+	}
+	return err
+}
+
+// statusError tells missing pages and refusals apart from other failed
+// responses.
+func statusError(code int, err error) error {
+	switch code {
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %v", ErrBlocked, err)
+	}
+	return err
+}
@@ -0,0 +1,385 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+const (
+	fantlabUrl    = "https://fantlab.ru"
+	fantlabApiUrl = "https://api.fantlab.ru"
+)
+
+// FantlabSource reads works and editions from the JSON API of Fantlab,
+// which knows the original titles, cycles and awards of translated science
+// fiction and fantasy. A work link fills FirstYear but not Year, Publisher
+// or Isbn; an edition link fills those and takes the rest from the work the
+// edition prints. Title queries find works, ISBN queries find editions.
+type FantlabSource struct {
+	Env *Env
+}
+
+func (FantlabSource) Name() string      { return "fantlab" }
+func (FantlabSource) Domains() []string { return []string{"fantlab.ru"} }
+
+func (FantlabSource) ExpectedFields() []string {
+	return []string{"Name", "InitName", "Authors", "FirstYear", "Genres", "Summary"}
+}
+
+// KnownLinks is empty until a work page is picked from the live site;
+// "doctor_links" in the config can name one meanwhile.
+func (FantlabSource) KnownLinks() []string {
+	return nil
+}
+
+var (
+	fantlabWorkRe    = regexp.MustCompile(`^/work(\d+)$`)
+	fantlabEditionRe = regexp.MustCompile(`^/edition(\d+)$`)
+)
+
+// flGenre is a genre of the work classification, with the more precise
+// genres under it.
+type flGenre struct {
+	Label  string    `json:"label"`
+	Genres []flGenre `json:"genre"`
+}
+
+type flWork struct {
+	WorkId      int    `json:"work_id"`
+	Name        string `json:"work_name"`
+	NameOrig    string `json:"work_name_orig"`
+	Year        int    `json:"work_year"`
+	Type        string `json:"work_type"`
+	Description string `json:"work_description"`
+	Image       string `json:"image"`
+	Authors     []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"authors"`
+	Rating struct {
+		Rating string `json:"rating"`
+	} `json:"rating"`
+	Classificatory struct {
+		GenreGroups []struct {
+			Label  string    `json:"label"`
+			Genres []flGenre `json:"genre"`
+		} `json:"genre_group"`
+	} `json:"classificatory"`
+	Parents struct {
+		Cycles [][]struct {
+			WorkId int    `json:"work_id"`
+			Name   string `json:"work_name"`
+			Type   string `json:"work_type"`
+		} `json:"cycles"`
+	} `json:"parents"`
+	Awards struct {
+		Win []struct {
+			Name    string `json:"award_name"`
+			RusName string `json:"award_rusname"`
+		} `json:"win"`
+	} `json:"awards"`
+	Translations []struct {
+		Lang         string `json:"lang"`
+		Translations []struct {
+			Translators []struct {
+				Name string `json:"name"`
+			} `json:"translators"`
+		} `json:"translations"`
+	} `json:"translations"`
+}
+
+type flEdition struct {
+	EditionId   int      `json:"edition_id"`
+	Name        string   `json:"edition_name"`
+	Year        int      `json:"year"`
+	Isbns       []string `json:"isbns"`
+	Image       string   `json:"image"`
+	Description string   `json:"description"`
+	Creators    struct {
+		Authors []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"authors"`
+		Publishers []struct {
+			Name string `json:"name"`
+		} `json:"publishers"`
+	} `json:"creators"`
+	Series []struct {
+		Name string `json:"name"`
+	} `json:"series"`
+}
+
+// Search looks for works by title and author, and for editions by ISBN.
+func (source FantlabSource) Search(ctx context.Context, query string) ([]string, error) {
+	if model.IsIsbn(query) {
+		return source.searchEditions(ctx, model.NormalizeIsbn(query))
+	}
+	params := url.Values{"q": {query}, "page": {"1"}, "onlymatches": {"1"}}
+	var found []struct {
+		WorkId int `json:"work_id"`
+	}
+	if err := source.Env.getJson(ctx, fantlabApiUrl+"/search-works?"+params.Encode(), &found); err != nil {
+		return nil, err
+	}
+	var links []string
+	for i, work := range found {
+		if i == 5 {
+			break
+		}
+		links = append(links, fantlabWorkLink(work.WorkId))
+	}
+	return links, nil
+}
+
+func (source FantlabSource) searchEditions(ctx context.Context, isbn string) ([]string, error) {
+	params := url.Values{"q": {isbn}, "page": {"1"}}
+	var found []struct {
+		EditionId int `json:"edition_id"`
+	}
+	if err := source.Env.getJson(ctx, fantlabApiUrl+"/search-editions?"+params.Encode(), &found); err != nil {
+		return nil, err
+	}
+	var links []string
+	for i, edition := range found {
+		if i == 5 {
+			break
+		}
+		links = append(links, fantlabEditionLink(edition.EditionId))
+	}
+	return links, nil
+}
+
+func fantlabWorkLink(id int) string {
+	return fmt.Sprintf("%s/work%d", fantlabUrl, id)
+}
+
+func fantlabEditionLink(id int) string {
+	return fmt.Sprintf("%s/edition%d", fantlabUrl, id)
+}
+
+func (source FantlabSource) Fetch(ctx context.Context, link string) (model.Book, error) {
+	book := model.NewBook()
+	err := source.fetch(ctx, link, &book)
+	book.InitFileName()
+	return book, sourceError("fantlab", link, source.Env.checkParsed(source, &book, err))
+}
+
+func (source FantlabSource) fetch(ctx context.Context, link string, book *model.Book) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Hostname(), "fantlab.ru") {
+		return fmt.Errorf("%w: not a Fantlab link", ErrNotFound)
+	}
+	if m := fantlabEditionRe.FindStringSubmatch(path); m != nil {
+		return source.fetchEdition(ctx, m[1], book)
+	}
+	m := fantlabWorkRe.FindStringSubmatch(path)
+	if m == nil {
+		return fmt.Errorf("%w: not a Fantlab work or edition link", ErrNotFound)
+	}
+	return source.fetchWork(ctx, m[1], book)
+}
+
+func (source FantlabSource) fetchWork(ctx context.Context, id string, book *model.Book) error {
+	var work flWork
+	if err := source.Env.getJson(ctx, fantlabApiUrl+"/work/"+id+"/extended", &work); err != nil {
+		return err
+	}
+
+	book.FantlabUrl = fantlabWorkLink(work.WorkId)
+	book.Name = work.Name
+	book.InitName = work.NameOrig
+	if work.Year > 0 {
+		book.FirstYear = strconv.Itoa(work.Year)
+	}
+	book.Summary = strings.TrimSpace(work.Description)
+	if work.Image != "" {
+		book.PosterUrl = fantlabUrl + work.Image
+	}
+	for _, author := range work.Authors {
+		if author.Type == "autor" {
+			book.Authors = append(book.Authors, model.ParsePerson(author.Name, true))
+		}
+	}
+	// a work has many translations, the first one is the best known
+	for _, lang := range work.Translations {
+		if lang.Lang == "русский" && len(lang.Translations) > 0 {
+			for _, translator := range lang.Translations[0].Translators {
+				book.Translators = append(book.Translators, model.ParsePerson(translator.Name, true))
+			}
+			break
+		}
+	}
+	for _, group := range work.Classificatory.GenreGroups {
+		if group.Label == "Жанры/поджанры" {
+			source.appendGenres(book, group.Genres)
+		}
+	}
+	// an award won in several nominations is listed once
+	seen := make(map[string]bool)
+	for _, award := range work.Awards.Win {
+		name := award.RusName
+		if name == "" {
+			name = award.Name
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			book.Awards = append(book.Awards, name)
+		}
+	}
+	if work.Rating.Rating != "" && work.Rating.Rating != "0" {
+		book.SetRating("fantlab", work.Rating.Rating)
+	}
+
+	// the cycles are listed from the outermost, the last one holds the work
+	if len(work.Parents.Cycles) > 0 {
+		chain := work.Parents.Cycles[0]
+		if len(chain) > 0 {
+			cycle := chain[len(chain)-1]
+			book.Cycle = cycle.Name
+			// the position is a nice to have, the book is fine without it
+			if number, err := source.cycleNumber(ctx, cycle.WorkId, &work); err == nil {
+				book.CycleNumber = number
+			} else {
+				source.Env.logf("fantlab: %s: %v\n", book.FantlabUrl, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (source FantlabSource) fetchEdition(ctx context.Context, id string, book *model.Book) error {
+	var edition flEdition
+	if err := source.Env.getJson(ctx, fantlabApiUrl+"/edition/"+id, &edition); err != nil {
+		return err
+	}
+
+	book.FantlabUrl = fantlabEditionLink(edition.EditionId)
+	book.Name = edition.Name
+	if edition.Year > 0 {
+		book.Year = strconv.Itoa(edition.Year)
+	}
+	book.Summary = strings.TrimSpace(edition.Description)
+	if edition.Image != "" {
+		book.PosterUrl = fantlabUrl + edition.Image
+	}
+	for _, author := range edition.Creators.Authors {
+		if author.Type == "autor" {
+			book.Authors = append(book.Authors, model.ParsePerson(author.Name, true))
+		}
+	}
+	if len(edition.Creators.Publishers) > 0 {
+		book.Publisher = edition.Creators.Publishers[0].Name
+	}
+	if len(edition.Series) > 0 {
+		book.Series = edition.Series[0].Name
+	}
+	// an edition printed under several ISBNs lists them all
+	var isbns []string
+	for _, isbn := range edition.Isbns {
+		if model.IsIsbn(isbn) {
+			isbns = append(isbns, isbn)
+		}
+	}
+	book.Isbn = strings.Join(isbns, ", ")
+
+	// the edition knows neither the original title nor the cycle, its work
+	// does; an edition without a work found is still an edition
+	work, err := source.editionWork(ctx, book)
+	if err != nil {
+		source.Env.logf("fantlab: %s: %v\n", book.FantlabUrl, err)
+		return nil
+	}
+	book.InitName = work.InitName
+	book.FirstYear = work.FirstYear
+	book.Genres = work.Genres
+	book.Cycle = work.Cycle
+	book.CycleNumber = work.CycleNumber
+	book.Awards = work.Awards
+	book.Translators = work.Translators
+	book.Ratings = work.Ratings
+	if book.Summary == "" {
+		book.Summary = work.Summary
+	}
+	return nil
+}
+
+// editionWork finds the work an edition prints: the work search result
+// with the title of the edition by its first author.
+func (source FantlabSource) editionWork(ctx context.Context, edition *model.Book) (model.Book, error) {
+	work := model.NewBook()
+	query := edition.Name
+	if len(edition.Authors) > 0 {
+		query += " " + edition.Authors[0].LastName
+	}
+	params := url.Values{"q": {query}, "page": {"1"}, "onlymatches": {"1"}}
+	var found []struct {
+		WorkId  int    `json:"work_id"`
+		RusName string `json:"rusname"`
+		Author  string `json:"autor_rusname"`
+	}
+	if err := source.Env.getJson(ctx, fantlabApiUrl+"/search-works?"+params.Encode(), &found); err != nil {
+		return work, err
+	}
+	for _, w := range found {
+		if !sameTitle(w.RusName, edition.Name) {
+			continue
+		}
+		if len(edition.Authors) > 0 && !strings.Contains(w.Author, edition.Authors[0].LastName) {
+			continue
+		}
+		err := source.fetchWork(ctx, strconv.Itoa(w.WorkId), &work)
+		return work, err
+	}
+	return work, fmt.Errorf("%w: no work titled %q", ErrNotFound, edition.Name)
+}
+
+// sameTitle compares titles ignoring case, ё and surrounding space.
+func sameTitle(a string, b string) bool {
+	fold := func(s string) string {
+		return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "ё", "е")
+	}
+	return fold(a) == fold(b)
+}
+
+func (source FantlabSource) appendGenres(book *model.Book, genres []flGenre) {
+	for _, genre := range genres {
+		source.Env.appendGenre(book, genre.Label)
+		source.appendGenres(book, genre.Genres)
+	}
+}
+
+// cycleNumber returns the place of the work among the works of the same
+// type in the cycle, so that the stories between the novels of a cycle do
+// not count.
+func (source FantlabSource) cycleNumber(ctx context.Context, cycleId int, work *flWork) (string, error) {
+	var cycle struct {
+		Subworks []struct {
+			WorkId int    `json:"work_id"`
+			Type   string `json:"work_type"`
+		} `json:"subworks"`
+	}
+	if err := source.Env.getJson(ctx, fmt.Sprintf("%s/work/%d/subworks?depth=1", fantlabApiUrl, cycleId), &cycle); err != nil {
+		return "", err
+	}
+	n := 0
+	for _, sub := range cycle.Subworks {
+		if sub.Type != work.Type {
+			continue
+		}
+		n++
+		if sub.WorkId == work.WorkId {
+			return strconv.Itoa(n), nil
+		}
+	}
+	return "", fmt.Errorf("%w: work %d not in cycle %d", ErrNotFound, work.WorkId, cycleId)
+}
@@ -0,0 +1,25 @@
+package sources
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFantlabSearch(t *testing.T) {
+	source := FantlabSource{Env: newMirror(t)}
+	links, err := source.Search(context.Background(), "хоббит толкин")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://fantlab.ru/work2", "https://fantlab.ru/work3105"}
+	if strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", links, want)
+	}
+
+	links, err = source.Search(context.Background(), "978-5-17-085929-0")
+	want = []string{"https://fantlab.ru/edition140000"}
+	if err != nil || strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, %v for an ISBN, want %v", links, err, want)
+	}
+}
@@ -0,0 +1,236 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+var (
+	update = flag.Bool("update", false, "rewrite the golden files from what the parsers extract")
+	record = flag.Bool("record", false, "fetch the fixture pages from the live sites into testdata/pages")
+)
+
+// fixtures are saved pages under testdata/pages/<host>/<path>, with .json
+// or .html added unless the path names a file such as search.json, and the books
+// extracted from them in testdata/golden/<name>.json.
+//
+// Pages written by hand drift from the real sites. To save the live pages
+// instead, run
+//
+//	go test -run TestRecordFixtures -record
+//	go test -update
+//
+// and review the changes of the golden files.
+var fixtures = []struct {
+	name   string
+	source string
+	link   string
+}{
+	{"labirint", "labirint", "https://www.labirint.ru/books/812345/"},
+	{"livelib", "livelib", "https://www.livelib.ru/book/1000529743-master-i-margarita-mihail-bulgakov"},
+	{"goodreads", "goodreads", "https://www.goodreads.com/book/show/117833.The_Master_and_Margarita"},
+	{"openlibrary", "openlibrary", "https://openlibrary.org/books/OL7353617M"},
+	{"googlebooks", "googlebooks", "https://books.google.com/books?id=zyTCAlFPjgYC"},
+	{"fantlab", "fantlab", "https://fantlab.ru/work2"},
+	{"fantlab-edition", "fantlab", "https://fantlab.ru/edition140000"},
+	{"chitaigorod", "chitaigorod", "https://www.chitai-gorod.ru/product/master-i-margarita-2897538"},
+	{"ozon", "ozon", "https://www.ozon.ru/product/master-i-margarita-bulgakov-mihail-afanasevich-138212283/?asb=x&sh=y"},
+	{"litres", "litres", "https://www.litres.ru/book/dzhon-r-r-tolkin/hobbit-ili-tuda-i-obratno-119316/chitat-onlayn/"},
+}
+
+// newMirror starts a server answering for every host with the saved pages
+// and returns an environment sending all requests to it.
+func newMirror(t *testing.T) *Env {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join("testdata", "pages", r.Host, filepath.FromSlash(strings.Trim(r.URL.Path, "/")))
+		if info, err := os.Stat(name); err != nil || info.IsDir() {
+			if _, err := os.Stat(name + ".json"); err == nil {
+				name += ".json"
+			} else {
+				name += ".html"
+			}
+		}
+		http.ServeFile(w, r, name)
+	}))
+	t.Cleanup(srv.Close)
+
+	hosts, err := os.ReadDir(filepath.Join("testdata", "pages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnv()
+	env.Limiter = nil
+	env.Mirrors = make(map[string]string)
+	for _, host := range hosts {
+		env.Mirrors[host.Name()] = srv.URL
+	}
+	return env
+}
+
+// TestRecordFixtures fetches every page the fixtures need from the live
+// sites and saves it where the mirror of newMirror looks for it.
+func TestRecordFixtures(t *testing.T) {
+	if !*record {
+		t.Skip("run with -record to fetch the pages from the live sites")
+	}
+	env := NewEnv()
+	env.Transport = recorder{base: http.DefaultTransport}
+	registry := NewRegistry()
+	for _, source := range Builtin(env) {
+		registry.Register(source)
+	}
+	for _, fixture := range fixtures {
+		if _, err := registry.Get(fixture.source).Fetch(context.Background(), fixture.link); err != nil {
+			t.Errorf("%s: %v", fixture.name, err)
+		}
+	}
+}
+
+// recorder saves the successful responses under testdata/pages.
+type recorder struct {
+	base http.RoundTripper
+}
+
+func (r recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	name := filepath.Join("testdata", "pages", req.URL.Host, filepath.FromSlash(strings.Trim(req.URL.Path, "/")))
+	if path.Ext(req.URL.Path) == "" {
+		if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+			name += ".json"
+		} else {
+			name += ".html"
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	return resp, os.WriteFile(name, body, 0644)
+}
+
+func TestFetchFixtures(t *testing.T) {
+	env := newMirror(t)
+	registry := NewRegistry()
+	for _, source := range Builtin(env) {
+		registry.Register(source)
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.name, func(t *testing.T) {
+			book, err := registry.Get(fixture.source).Fetch(context.Background(), fixture.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "golden", fixture.name+".json"), &book)
+		})
+	}
+}
+
+// TestCheckFixtures runs the checks of book doctor against the saved
+// pages, which must fill every field the sources expect.
+func TestCheckFixtures(t *testing.T) {
+	env := newMirror(t)
+	registry := NewRegistry()
+	for _, source := range Builtin(env) {
+		registry.Register(source)
+	}
+	for _, f := range fixtures {
+		for _, checkup := range Check(context.Background(), registry.Get(f.source), []string{f.link}) {
+			if !checkup.Ok() {
+				t.Errorf("%s: %s: missing %v, error %v", checkup.Source, checkup.Url, checkup.Missing, checkup.Err)
+			}
+		}
+	}
+}
+
+// TestKnownLinks checks that the pages book doctor fetches belong to their
+// sources; whether they still exist can only be seen online.
+func TestKnownLinks(t *testing.T) {
+	registry := NewRegistry()
+	for _, source := range Builtin(NewEnv()) {
+		registry.Register(source)
+	}
+	for _, name := range registry.Names() {
+		source := registry.Get(name)
+		checkable, ok := source.(Checkable)
+		if !ok {
+			continue
+		}
+		for _, link := range checkable.KnownLinks() {
+			if owner := registry.ForLink(link); owner == nil || owner.Name() != source.Name() {
+				t.Errorf("%s: %s belongs to %v", source.Name(), link, owner)
+			}
+		}
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	book := model.NewBook()
+	book.Name = "Хоббит"
+	book.Genres["сказки"] = ""
+	got := MissingFields(&book, []string{"Name", "Authors", "Genres", "Ratings", "Year"})
+	want := []string{"Authors", "Ratings", "Year"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFetchMissingPage(t *testing.T) {
+	env := newMirror(t)
+	registry := NewRegistry()
+	for _, source := range Builtin(env) {
+		registry.Register(source)
+	}
+	_, err := registry.Get("livelib").Fetch(context.Background(), "https://www.livelib.ru/book/1-missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func checkGolden(t *testing.T, path string, book *model.Book) {
+	t.Helper()
+	got, err := json.MarshalIndent(book, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("book differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
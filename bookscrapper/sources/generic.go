@@ -0,0 +1,275 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// GenericSource reads book pages of any site from the metadata published
+// for search engines and social networks: schema.org Book or Product data
+// in JSON-LD, schema.org microdata and OpenGraph tags, in this order of
+// preference. It fetches the links no other source owns, so it has no
+// domains and can't search.
+type GenericSource struct {
+	Env *Env
+}
+
+func (GenericSource) Name() string      { return "generic" }
+func (GenericSource) Domains() []string { return nil }
+
+func (GenericSource) Search(ctx context.Context, query string) ([]string, error) {
+	return nil, nil
+}
+
+func (source GenericSource) Fetch(ctx context.Context, link string) (model.Book, error) {
+	book := model.NewBook()
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return book, sourceError("generic", link, fmt.Errorf("%w: not a web page", ErrNotFound))
+	}
+
+	c := source.Env.NewCollector(ctx)
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		readJsonLd(&book, e.DOM)
+		readMicrodata(&book, e.DOM)
+		readOpenGraph(&book, e.DOM)
+		if book.PosterUrl != "" {
+			book.PosterUrl = e.Request.AbsoluteURL(book.PosterUrl)
+		}
+	})
+	err = visitPage(c, link)
+	book.OtherUrl = link
+	book.InitFileName()
+	return book, sourceError("generic", link, source.Env.checkParsed(source, &book, err))
+}
+
+// setEmpty sets the field unless a more reliable kind of metadata already
+// did.
+func setEmpty(field *string, value string) {
+	if *field == "" {
+		*field = strings.TrimSpace(value)
+	}
+}
+
+// readJsonLd reads the first Book in the JSON-LD of the page, or the first
+// Product when there is no Book.
+func readJsonLd(book *model.Book, doc *goquery.Selection) {
+	var found map[string]interface{}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		for _, item := range ldItems(data) {
+			switch {
+			case ldHasType(item, "Book"):
+				found = item
+				return false
+			case ldHasType(item, "Product") && found == nil:
+				found = item
+			}
+		}
+		return true
+	})
+	if found == nil {
+		return
+	}
+
+	setEmpty(&book.Name, ldText(found["name"]))
+	setEmpty(&book.Summary, ldText(found["description"]))
+	setEmpty(&book.Publisher, ldText(found["publisher"]))
+	if book.Publisher == "" && ldHasType(found, "Product") {
+		setEmpty(&book.Publisher, ldText(found["brand"]))
+	}
+	setEmpty(&book.Year, yearRe.FindString(ldText(found["datePublished"])))
+	for _, key := range []string{"isbn", "gtin13", "gtin"} {
+		if isbn := ldText(found[key]); model.IsIsbn(isbn) {
+			setEmpty(&book.Isbn, isbn)
+		}
+	}
+	for _, image := range ldList(found["image"]) {
+		setEmpty(&book.PosterUrl, ldText(image))
+	}
+	if rating, ok := found["aggregateRating"].(map[string]interface{}); ok {
+		if value := ldText(rating["ratingValue"]); value != "" {
+			book.SetRating("generic", value)
+		}
+	}
+	people := []struct {
+		key  string
+		list *[]model.Person
+	}{
+		{"author", &book.Authors},
+		{"illustrator", &book.Painters},
+		{"editor", &book.Editors},
+		{"translator", &book.Translators},
+	}
+	for _, p := range people {
+		if len(*p.list) > 0 {
+			continue
+		}
+		for _, person := range ldList(found[p.key]) {
+			if name := ldText(person); name != "" {
+				*p.list = append(*p.list, model.ParsePerson(name, true))
+			}
+		}
+	}
+	for _, genre := range ldList(found["genre"]) {
+		if name := ldText(genre); name != "" {
+			book.Genres[strings.ToLower(name)] = ""
+		}
+	}
+}
+
+// ldItems returns the objects of JSON-LD data, which may be a list of
+// them or hold them in its @graph.
+func ldItems(data interface{}) []map[string]interface{} {
+	var items []map[string]interface{}
+	for _, v := range ldList(data) {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		items = append(items, obj)
+		if graph, ok := obj["@graph"]; ok {
+			items = append(items, ldItems(graph)...)
+		}
+	}
+	return items
+}
+
+func ldHasType(item map[string]interface{}, name string) bool {
+	for _, t := range ldList(item["@type"]) {
+		if s, ok := t.(string); ok && strings.TrimPrefix(s, "schema:") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ldList returns the value as a list: JSON-LD gives single values without
+// one.
+func ldList(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{v}
+}
+
+// ldText returns the text of a value, which is the name of an object such
+// as a Person or the URL of an ImageObject.
+func ldText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	case map[string]interface{}:
+		for _, key := range []string{"name", "url", "@value"} {
+			if s := ldText(v[key]); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			return ldText(v[0])
+		}
+	}
+	return ""
+}
+
+// readMicrodata reads the itemprop attributes inside a schema.org Book or
+// Product item, and the ISBN anywhere on the page.
+func readMicrodata(book *model.Book, doc *goquery.Selection) {
+	scope := doc.Find(`[itemtype$="schema.org/Book"]`).First()
+	if scope.Length() == 0 {
+		scope = doc.Find(`[itemtype$="schema.org/Product"]`).First()
+	}
+	prop := func(s *goquery.Selection, name string) string {
+		e := s.Find(`[itemprop="` + name + `"]`).First()
+		if e.Length() == 0 {
+			return ""
+		}
+		for _, attr := range []string{"content", "src", "href", "datetime"} {
+			if v, ok := e.Attr(attr); ok {
+				return v
+			}
+		}
+		return e.Text()
+	}
+
+	if isbn := prop(doc, "isbn"); model.IsIsbn(isbn) {
+		setEmpty(&book.Isbn, isbn)
+	}
+	if scope.Length() == 0 {
+		return
+	}
+	setEmpty(&book.Name, prop(scope, "name"))
+	setEmpty(&book.Summary, prop(scope, "description"))
+	setEmpty(&book.PosterUrl, prop(scope, "image"))
+	setEmpty(&book.Year, yearRe.FindString(prop(scope, "datePublished")))
+	if publisher := scope.Find(`[itemprop="publisher"]`).First(); publisher.Length() > 0 {
+		if name := prop(publisher, "name"); name != "" {
+			setEmpty(&book.Publisher, name)
+		} else {
+			setEmpty(&book.Publisher, publisher.Text())
+		}
+	}
+	if len(book.Authors) == 0 {
+		scope.Find(`[itemprop="author"]`).Each(func(i int, s *goquery.Selection) {
+			name := prop(s, "name")
+			if name == "" {
+				name = s.Text()
+			}
+			if name = strings.TrimSpace(name); name != "" {
+				book.Authors = append(book.Authors, model.ParsePerson(name, true))
+			}
+		})
+	}
+}
+
+// readOpenGraph reads the og: tags and the book: tags of the OpenGraph
+// book type.
+func readOpenGraph(book *model.Book, doc *goquery.Selection) {
+	meta := func(property string) []string {
+		var values []string
+		doc.Find(`meta[property="` + property + `"]`).Each(func(i int, s *goquery.Selection) {
+			if v := strings.TrimSpace(s.AttrOr("content", "")); v != "" {
+				values = append(values, v)
+			}
+		})
+		return values
+	}
+	first := func(property string) string {
+		if values := meta(property); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	setEmpty(&book.Name, first("og:title"))
+	setEmpty(&book.Summary, first("og:description"))
+	setEmpty(&book.PosterUrl, first("og:image"))
+	if isbn := first("book:isbn"); model.IsIsbn(isbn) {
+		setEmpty(&book.Isbn, isbn)
+	}
+	setEmpty(&book.Year, yearRe.FindString(first("book:release_date")))
+	if len(book.Authors) == 0 {
+		for _, author := range meta("book:author") {
+			// authors are usually links to author pages, which say nothing
+			if !strings.Contains(author, "://") {
+				book.Authors = append(book.Authors, model.ParsePerson(author, true))
+			}
+		}
+	}
+}
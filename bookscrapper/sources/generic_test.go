@@ -0,0 +1,44 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenericFetch(t *testing.T) {
+	source := GenericSource{Env: newMirror(t)}
+	for _, name := range []string{"jsonld", "microdata", "opengraph"} {
+		t.Run(name, func(t *testing.T) {
+			book, err := source.Fetch(context.Background(), "https://books.example.com/book/"+name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "golden", "generic-"+name+".json"), &book)
+		})
+	}
+}
+
+func TestGenericFetchNoBook(t *testing.T) {
+	source := GenericSource{Env: newMirror(t)}
+	_, err := source.Fetch(context.Background(), "https://books.example.com/book/missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	_, err = source.Fetch(context.Background(), "ftp://books.example.com/book/jsonld")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for an ftp link, want ErrNotFound", err)
+	}
+}
+
+func TestGenericFetchBadIsbn(t *testing.T) {
+	source := GenericSource{Env: newMirror(t)}
+	book, err := source.Fetch(context.Background(), "https://books.example.com/book/bad-isbn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Name != "The Tombs of Atuan" || book.Isbn != "" {
+		t.Errorf("got name %q, ISBN %q", book.Name, book.Isbn)
+	}
+}
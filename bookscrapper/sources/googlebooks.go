@@ -0,0 +1,172 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"google.golang.org/api/books/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/googleapi/transport"
+	"google.golang.org/api/option"
+)
+
+// GoogleBooksSource reads volumes from the Google Books API. The API key
+// is optional, it only raises the quota.
+type GoogleBooksSource struct {
+	Env    *Env
+	ApiKey string
+}
+
+func (GoogleBooksSource) Name() string      { return "googlebooks" }
+func (GoogleBooksSource) Domains() []string { return []string{"books.google.com"} }
+
+func (GoogleBooksSource) ExpectedFields() []string {
+	return []string{"Name", "Authors", "Year", "Publisher", "Isbn", "Summary"}
+}
+
+// KnownLinks is the volume the Books API documentation uses as its
+// example.
+func (GoogleBooksSource) KnownLinks() []string {
+	return []string{"https://books.google.com/books?id=zyTCAlFPjgYC"}
+}
+
+func (source GoogleBooksSource) service(ctx context.Context) (*books.Service, error) {
+	var rt http.RoundTripper = source.Env.RoundTripper(ctx)
+	if source.ApiKey != "" {
+		rt = &transport.APIKey{Key: source.ApiKey, Transport: rt}
+	}
+	return books.NewService(ctx, option.WithHTTPClient(&http.Client{Transport: rt}))
+}
+
+// Search looks for volumes matching the query, which may use the intitle:
+// and inauthor: operators. ISBNs are looked up with isbn:.
+func (source GoogleBooksSource) Search(ctx context.Context, query string) ([]string, error) {
+	if model.IsIsbn(query) {
+		query = "isbn:" + model.NormalizeIsbn(query)
+	}
+	return source.search(ctx, query)
+}
+
+// SearchBook looks for volumes by title and author.
+func (source GoogleBooksSource) SearchBook(ctx context.Context, title string, author string) ([]string, error) {
+	query := "intitle:" + strconv.Quote(title)
+	if author != "" {
+		query += " inauthor:" + strconv.Quote(author)
+	}
+	return source.search(ctx, query)
+}
+
+func (source GoogleBooksSource) search(ctx context.Context, query string) ([]string, error) {
+	svc, err := source.service(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := svc.Volumes.List(query).MaxResults(5).PrintType("books").Context(ctx).Do()
+	if err != nil {
+		return nil, googleBooksError(err)
+	}
+	var links []string
+	for _, volume := range resp.Items {
+		links = append(links, googleBooksLink(volume.Id))
+	}
+	return links, nil
+}
+
+func googleBooksLink(id string) string {
+	return "https://books.google.com/books?id=" + url.QueryEscape(id)
+}
+
+func googleBooksError(err error) error {
+	err = googleError(err)
+	var e *googleapi.Error
+	if !errors.Is(err, ErrQuotaExceeded) && errors.As(err, &e) {
+		return statusError(e.Code, err)
+	}
+	return err
+}
+
+func (source GoogleBooksSource) Fetch(ctx context.Context, link string) (model.Book, error) {
+	book := model.NewBook()
+	err := source.fetch(ctx, link, &book)
+	book.InitFileName()
+	return book, sourceError("googlebooks", link, source.Env.checkParsed(source, &book, err))
+}
+
+func (source GoogleBooksSource) fetch(ctx context.Context, link string, book *model.Book) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+	id := u.Query().Get("id")
+	if id == "" || !strings.HasPrefix(u.Hostname(), "books.google.") {
+		return fmt.Errorf("%w: not a Google Books link", ErrNotFound)
+	}
+	svc, err := source.service(ctx)
+	if err != nil {
+		return err
+	}
+	volume, err := svc.Volumes.Get(id).Context(ctx).Do()
+	if err != nil {
+		return googleBooksError(err)
+	}
+	info := volume.VolumeInfo
+	if info == nil {
+		return nil
+	}
+
+	book.GoogleBooksUrl = googleBooksLink(volume.Id)
+	book.Name = info.Title
+	for _, author := range info.Authors {
+		book.Authors = append(book.Authors, model.ParsePerson(author, true))
+	}
+	book.Publisher = strings.Trim(info.Publisher, `"`)
+	book.Year = yearRe.FindString(info.PublishedDate)
+	for _, id := range info.IndustryIdentifiers {
+		if id.Type == "ISBN_13" || (id.Type == "ISBN_10" && book.Isbn == "") {
+			book.Isbn = id.Identifier
+		}
+	}
+	// the description is HTML, its paragraphs become lines
+	description := strings.NewReplacer("</p>", "</p>\n", "<br>", "\n").Replace(info.Description)
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(description)); err == nil {
+		book.Summary = strings.TrimSpace(doc.Text())
+	}
+	for _, category := range info.Categories {
+		if genre := googleBooksGenre(category); genre != "" {
+			book.Genres[genre] = ""
+		}
+	}
+	if images := info.ImageLinks; images != nil {
+		for _, image := range []string{images.ExtraLarge, images.Large, images.Medium, images.Small, images.Thumbnail} {
+			if image != "" {
+				image = strings.Replace(image, "http://", "https://", 1)
+				book.PosterUrl = strings.Replace(image, "&edge=curl", "", 1)
+				break
+			}
+		}
+	}
+	if info.AverageRating > 0 {
+		book.SetRating("googlebooks", strconv.FormatFloat(info.AverageRating, 'f', -1, 64))
+	}
+	return nil
+}
+
+// googleBooksGenre returns the most precise part of a category such as
+// "Fiction / Fantasy / General", here "fantasy".
+func googleBooksGenre(category string) string {
+	parts := strings.Split(category, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.ToLower(strings.TrimSpace(parts[i]))
+		if part != "" && part != "general" {
+			return part
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package sources
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGoogleBooksSearch(t *testing.T) {
+	source := GoogleBooksSource{Env: newMirror(t), ApiKey: "key"}
+	links, err := source.SearchBook(context.Background(), "The Google Story", "Vise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://books.google.com/books?id=zyTCAlFPjgYC",
+		"https://books.google.com/books?id=UzdNAQAAIAAJ",
+	}
+	if strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", links, want)
+	}
+}
+
+func TestGoogleBooksGenre(t *testing.T) {
+	tests := []struct {
+		category, genre string
+	}{
+		{"Fiction / Fantasy / General", "fantasy"},
+		{"Business & Economics / Entrepreneurship", "entrepreneurship"},
+		{"Fiction", "fiction"},
+		{"General", ""},
+	}
+	for _, test := range tests {
+		if genre := googleBooksGenre(test.category); genre != test.genre {
+			t.Errorf("%q: got %q, want %q", test.category, genre, test.genre)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Checkable is implemented by sources that can tell when their parser no
+// longer matches the site: a page where an expected field stays empty
+// usually means a selector stopped matching after a redesign.
+type Checkable interface {
+	// ExpectedFields names the Book fields the source fills for every
+	// ordinary book page.
+	ExpectedFields() []string
+	// KnownLinks are pages of books that should stay on the site for
+	// years, fetched to check the parser.
+	KnownLinks() []string
+}
+
+// RuleCheckable is implemented by sources made of extraction rules, such
+// as site definitions, which can point at the rules that stopped matching.
+type RuleCheckable interface {
+	// UnmatchedRules fetches the page like Fetch and describes the rules
+	// that found nothing on it.
+	UnmatchedRules(ctx context.Context, link string) (model.Book, []string, error)
+}
+
+// MissingFields returns the named fields of the book that are empty.
+// Unknown field names are reported as missing too.
+func MissingFields(book *model.Book, fields []string) []string {
+	var missing []string
+	v := reflect.ValueOf(book).Elem()
+	for _, name := range fields {
+		field := v.FieldByName(name)
+		if !field.IsValid() || field.IsZero() {
+			missing = append(missing, name)
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Map, reflect.Slice:
+			if field.Len() == 0 {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
+
+// checkParsed reports pages the parser could not get a title from and
+// warns about the other expected fields it left empty.
+func (env *Env) checkParsed(source Source, book *model.Book, err error) error {
+	if err != nil {
+		return err
+	}
+	if book.Name == "" {
+		return fmt.Errorf("%w: no title", ErrIncomplete)
+	}
+	if checkable, ok := source.(Checkable); ok {
+		if missing := MissingFields(book, checkable.ExpectedFields()); len(missing) > 0 {
+			env.logf("%s: %s: nothing found for %s\n", source.Name(), book.SourceUrl(source.Name()), strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// Checkup is the outcome of fetching a known page of a source.
+type Checkup struct {
+	Source string
+	Url    string
+	// Missing lists the expected fields that stayed empty.
+	Missing []string
+	// Unmatched describes the rules that found nothing on the page, for
+	// sources that can tell. Rules for optional data may rightly find
+	// nothing.
+	Unmatched []string
+	Err       error
+}
+
+func (checkup *Checkup) Ok() bool {
+	return checkup.Err == nil && len(checkup.Missing) == 0
+}
+
+// Check fetches the pages with the source and reports the expected fields
+// each of them lacks and, for a RuleCheckable source, the rules that found
+// nothing. The known links of the source are used when links is empty.
+func Check(ctx context.Context, source Source, links []string) []Checkup {
+	var expected []string
+	if checkable, ok := source.(Checkable); ok {
+		expected = checkable.ExpectedFields()
+		if len(links) == 0 {
+			links = checkable.KnownLinks()
+		}
+	}
+	var checkups []Checkup
+	for _, link := range links {
+		var book model.Book
+		var unmatched []string
+		var err error
+		if checkable, ok := source.(RuleCheckable); ok {
+			book, unmatched, err = checkable.UnmatchedRules(ctx, link)
+		} else {
+			book, err = source.Fetch(ctx, link)
+		}
+		checkup := Checkup{Source: source.Name(), Url: link, Unmatched: unmatched, Err: err}
+		if err == nil || errors.Is(err, ErrIncomplete) {
+			checkup.Missing = MissingFields(&book, expected)
+		}
+		checkups = append(checkups, checkup)
+	}
+	return checkups
+}
@@ -0,0 +1,40 @@
+package sources
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mirrorTransport sends the requests for some hosts to other base URLs,
+// such as a local server with saved pages. Links keep the real host, so
+// collectors and parsers see no difference.
+type mirrorTransport struct {
+	mirrors map[string]string
+	base    http.RoundTripper
+}
+
+func (t mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mirror, ok := t.mirrors[req.URL.Host]
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.URL.Scheme = u.Scheme
+	r.URL.Host = u.Host
+	r.URL.Path = strings.TrimSuffix(u.Path, "/") + req.URL.Path
+	r.URL.RawPath = ""
+	// the mirror learns which site was asked for from the Host header
+	r.Host = req.URL.Host
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	// relative links on the page resolve against the real address
+	resp.Request = req
+	return resp, nil
+}
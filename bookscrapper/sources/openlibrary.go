@@ -0,0 +1,281 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+const openLibraryUrl = "https://openlibrary.org"
+
+// OpenLibrarySource reads books from the JSON APIs of Open Library: an
+// edition, the work it belongs to and the work's authors.
+type OpenLibrarySource struct {
+	Env *Env
+}
+
+func (OpenLibrarySource) Name() string      { return "openlibrary" }
+func (OpenLibrarySource) Domains() []string { return []string{"openlibrary.org"} }
+
+func (OpenLibrarySource) ExpectedFields() []string {
+	return []string{"Name", "Authors", "PosterUrl", "Year", "Publisher", "Isbn"}
+}
+
+// KnownLinks is the edition the Open Library API documentation uses as
+// its example.
+func (OpenLibrarySource) KnownLinks() []string {
+	return []string{"https://openlibrary.org/books/OL7353617M"}
+}
+
+// olText is a text Open Library gives either as a string or as an object
+// with a value.
+type olText string
+
+func (text *olText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*text = olText(s)
+		return nil
+	}
+	var v struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*text = olText(v.Value)
+	return nil
+}
+
+type olKey struct {
+	Key string `json:"key"`
+}
+
+type olEdition struct {
+	Key           string   `json:"key"`
+	Title         string   `json:"title"`
+	Publishers    []string `json:"publishers"`
+	PublishDate   string   `json:"publish_date"`
+	Isbn13        []string `json:"isbn_13"`
+	Isbn10        []string `json:"isbn_10"`
+	Covers        []int    `json:"covers"`
+	Series        []string `json:"series"`
+	TranslationOf string   `json:"translation_of"`
+	Description   olText   `json:"description"`
+	Works         []olKey  `json:"works"`
+	Authors       []olKey  `json:"authors"`
+	Contributors  []struct {
+		Role string `json:"role"`
+		Name string `json:"name"`
+	} `json:"contributors"`
+}
+
+type olWork struct {
+	Key              string   `json:"key"`
+	Title            string   `json:"title"`
+	Description      olText   `json:"description"`
+	Subjects         []string `json:"subjects"`
+	FirstPublishDate string   `json:"first_publish_date"`
+	Covers           []int    `json:"covers"`
+	Authors          []struct {
+		Author olKey `json:"author"`
+	} `json:"authors"`
+}
+
+func (source OpenLibrarySource) Search(ctx context.Context, query string) ([]string, error) {
+	if model.IsIsbn(query) {
+		return []string{openLibraryUrl + "/isbn/" + model.NormalizeIsbn(query)}, nil
+	}
+	params := url.Values{
+		"q":      {query},
+		"fields": {"key,cover_edition_key,edition_key"},
+		"limit":  {"5"},
+	}
+	var found struct {
+		Docs []struct {
+			Key             string   `json:"key"`
+			CoverEditionKey string   `json:"cover_edition_key"`
+			EditionKey      []string `json:"edition_key"`
+		} `json:"docs"`
+	}
+	if err := source.Env.getJson(ctx, openLibraryUrl+"/search.json?"+params.Encode(), &found); err != nil {
+		return nil, err
+	}
+	var links []string
+	for _, doc := range found.Docs {
+		switch {
+		case doc.CoverEditionKey != "":
+			links = append(links, openLibraryUrl+"/books/"+doc.CoverEditionKey)
+		case len(doc.EditionKey) > 0:
+			links = append(links, openLibraryUrl+"/books/"+doc.EditionKey[0])
+		case doc.Key != "":
+			links = append(links, openLibraryUrl+doc.Key)
+		}
+	}
+	return links, nil
+}
+
+// Fetch reads the book from a link to an edition (/books/), a work
+// (/works/, read with its first edition) or an ISBN (/isbn/).
+func (source OpenLibrarySource) Fetch(ctx context.Context, link string) (model.Book, error) {
+	book := model.NewBook()
+	err := source.fetch(ctx, link, &book)
+	book.InitFileName()
+	return book, sourceError("openlibrary", link, source.Env.checkParsed(source, &book, err))
+}
+
+func (source OpenLibrarySource) fetch(ctx context.Context, link string, book *model.Book) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || !strings.HasSuffix(u.Hostname(), "openlibrary.org") {
+		return fmt.Errorf("%w: not an Open Library book link", ErrNotFound)
+	}
+
+	var edition olEdition
+	var work olWork
+	switch parts[0] {
+	case "books", "isbn":
+		if err := source.Env.getJson(ctx, openLibraryUrl+"/"+parts[0]+"/"+parts[1]+".json", &edition); err != nil {
+			return err
+		}
+		if len(edition.Works) > 0 {
+			if err := source.Env.getJson(ctx, openLibraryUrl+edition.Works[0].Key+".json", &work); err != nil {
+				return err
+			}
+		}
+	case "works":
+		if err := source.Env.getJson(ctx, openLibraryUrl+"/works/"+parts[1]+".json", &work); err != nil {
+			return err
+		}
+		var editions struct {
+			Entries []olEdition `json:"entries"`
+		}
+		err := source.Env.getJson(ctx, openLibraryUrl+work.Key+"/editions.json?limit=1", &editions)
+		if err == nil && len(editions.Entries) > 0 {
+			edition = editions.Entries[0]
+		}
+	default:
+		return fmt.Errorf("%w: not an Open Library book link", ErrNotFound)
+	}
+
+	book.OpenLibraryUrl = openLibraryUrl + work.Key
+	if edition.Key != "" {
+		book.OpenLibraryUrl = openLibraryUrl + edition.Key
+	}
+	book.Name = edition.Title
+	if book.Name == "" {
+		book.Name = work.Title
+	}
+	book.InitName = edition.TranslationOf
+	book.Year = yearRe.FindString(edition.PublishDate)
+	book.FirstYear = yearRe.FindString(work.FirstPublishDate)
+	if len(edition.Publishers) > 0 {
+		book.Publisher = edition.Publishers[0]
+	}
+	if len(edition.Series) > 0 {
+		book.Series = edition.Series[0]
+	}
+	if len(edition.Isbn13) > 0 {
+		book.Isbn = edition.Isbn13[0]
+	} else if len(edition.Isbn10) > 0 {
+		book.Isbn = edition.Isbn10[0]
+	}
+	book.Summary = string(edition.Description)
+	if book.Summary == "" {
+		book.Summary = string(work.Description)
+	}
+	for _, id := range append(edition.Covers, work.Covers...) {
+		if id > 0 {
+			book.PosterUrl = fmt.Sprintf("https://covers.openlibrary.org/b/id/%d-L.jpg", id)
+			break
+		}
+	}
+	for _, subject := range work.Subjects {
+		genre, tag := openLibrarySubject(subject)
+		if genre != "" {
+			book.Genres[genre] = ""
+		} else if tag != "" {
+			book.Tags[tag] = ""
+		}
+	}
+
+	authors := edition.Authors
+	for _, a := range work.Authors {
+		authors = append(authors, a.Author)
+	}
+	seen := make(map[string]bool)
+	for _, a := range authors {
+		if a.Key == "" || seen[a.Key] {
+			continue
+		}
+		seen[a.Key] = true
+		var author struct {
+			Name string `json:"name"`
+		}
+		if err := source.Env.getJson(ctx, openLibraryUrl+a.Key+".json", &author); err != nil {
+			return err
+		}
+		book.Authors = append(book.Authors, model.ParsePerson(author.Name, true))
+	}
+	for _, c := range edition.Contributors {
+		person := model.ParsePerson(c.Name, true)
+		switch strings.ToLower(c.Role) {
+		case "translator":
+			book.Translators = append(book.Translators, person)
+		case "illustrator":
+			book.Painters = append(book.Painters, person)
+		case "editor":
+			book.Editors = append(book.Editors, person)
+		}
+	}
+
+	if work.Key != "" {
+		var ratings struct {
+			Summary struct {
+				Average float64 `json:"average"`
+			} `json:"summary"`
+		}
+		// ratings are a nice to have, the book is fine without them
+		err := source.Env.getJson(ctx, openLibraryUrl+work.Key+"/ratings.json", &ratings)
+		if err == nil && ratings.Summary.Average > 0 {
+			book.SetRating("openlibrary", fmt.Sprintf("%.2f", ratings.Summary.Average))
+		}
+	}
+	return nil
+}
+
+// olCatalogueSubjects are subjects describing the copies Open Library
+// holds rather than the book.
+var olCatalogueSubjects = map[string]bool{
+	"accessible book":  true,
+	"protected daisy":  true,
+	"in library":       true,
+	"lending library":  true,
+	"large type books": true,
+	"overdrive":        true,
+}
+
+// openLibrarySubject sorts a subject of a work: "Fiction, fantasy,
+// general" and "Science fiction" are genres, catalogue notes such as
+// "Accessible book" or "nyt:..." are dropped and the rest are tags.
+func openLibrarySubject(subject string) (genre string, tag string) {
+	s := strings.ToLower(strings.TrimSpace(subject))
+	if s == "" || strings.Contains(s, ":") || olCatalogueSubjects[s] {
+		return "", ""
+	}
+	if rest, ok := strings.CutPrefix(s, "fiction, "); ok {
+		rest = strings.TrimSuffix(rest, ", general")
+		return rest, ""
+	}
+	if s == "fiction" || strings.HasSuffix(s, " fiction") {
+		return s, ""
+	}
+	return "", s
+}
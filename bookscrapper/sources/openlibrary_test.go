@@ -0,0 +1,46 @@
+package sources
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOpenLibrarySearch(t *testing.T) {
+	source := OpenLibrarySource{Env: newMirror(t)}
+	links, err := source.Search(context.Background(), "fantastic mr fox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://openlibrary.org/books/OL7353617M",
+		"https://openlibrary.org/books/OL21123960M",
+		"https://openlibrary.org/works/OL99999W",
+	}
+	if strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", links, want)
+	}
+
+	links, err = source.Search(context.Background(), "978-0-14-032872-1")
+	if err != nil || len(links) != 1 || links[0] != "https://openlibrary.org/isbn/9780140328721" {
+		t.Errorf("got %v, %v for an ISBN", links, err)
+	}
+}
+
+func TestOpenLibrarySubject(t *testing.T) {
+	tests := []struct {
+		subject, genre, tag string
+	}{
+		{"Fiction, fantasy, general", "fantasy", ""},
+		{"Science fiction", "science fiction", ""},
+		{"Foxes", "", "foxes"},
+		{"Accessible book", "", ""},
+		{"nyt:children's-books=1988-01-01", "", ""},
+	}
+	for _, test := range tests {
+		genre, tag := openLibrarySubject(test.subject)
+		if genre != test.genre || tag != test.tag {
+			t.Errorf("%q: got %q, %q, want %q, %q", test.subject, genre, tag, test.genre, test.tag)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sources
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainLimiter spaces out requests to the same host, whichever collector
+// or goroutine makes them. Every request after the first waits the host's
+// delay plus a random part of up to the same length.
+type DomainLimiter struct {
+	mu     sync.Mutex
+	next   map[string]time.Time
+	delays map[string]time.Duration
+	// Default is the delay for hosts without a delay of their own.
+	Default time.Duration
+}
+
+func NewDomainLimiter(delay time.Duration) *DomainLimiter {
+	return &DomainLimiter{
+		next:    make(map[string]time.Time),
+		delays:  make(map[string]time.Duration),
+		Default: delay,
+	}
+}
+
+// SetDelay sets the delay for a domain and its subdomains.
+func (limiter *DomainLimiter) SetDelay(domain string, delay time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.delays[domain] = delay
+}
+
+func (limiter *DomainLimiter) delay(host string) time.Duration {
+	for domain, delay := range limiter.delays {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return delay
+		}
+	}
+	return limiter.Default
+}
+
+// Wait blocks until a request to the host may be made or the context is
+// done.
+func (limiter *DomainLimiter) Wait(ctx context.Context, host string) error {
+	limiter.mu.Lock()
+	now := time.Now()
+	at := limiter.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	delay := limiter.delay(host)
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)))
+	}
+	limiter.next[host] = at.Add(delay)
+	limiter.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedTransport makes collector requests obey the domain limiter and
+// the context of the lookup they belong to.
+type limitedTransport struct {
+	ctx     context.Context
+	limiter *DomainLimiter
+	base    http.RoundTripper
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.limiter != nil {
+		if err := t.limiter.Wait(t.ctx, req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,296 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+	"google.golang.org/api/customsearch/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/googleapi/transport"
+	"google.golang.org/api/option"
+)
+
+// Searcher finds links to pages of a site matching a query. The site is a
+// host optionally followed by a path prefix, e.g. "labirint.ru/books".
+type Searcher interface {
+	Name() string
+	Search(ctx context.Context, query string, site string, n int) ([]string, error)
+}
+
+type SearchSettings struct {
+	// Backends lists the search backends to try in order: "google",
+	// "site" for the stores' own search pages and "duckduckgo".
+	Backends []string `json:"backends"`
+	// ApiKey and Cx are the key and the engine id of Google Custom Search.
+	// The google backend is skipped without them.
+	ApiKey string `json:"api_key"`
+	Cx     string `json:"cx"`
+	// Sites overrides the search pages of the stores by domain.
+	Sites map[string]SiteSearch `json:"sites"`
+	// GoogleEndpoint and DuckDuckGoEndpoint replace the addresses of the
+	// services, e.g. with local stand-ins.
+	GoogleEndpoint     string `json:"google_endpoint"`
+	DuckDuckGoEndpoint string `json:"duckduckgo_endpoint"`
+}
+
+// NewSearcher builds the searcher trying the backends of the settings in
+// order. The backends scraping web pages fetch them through env.
+func NewSearcher(settings SearchSettings, env *Env) (Searcher, error) {
+	var fallback FallbackSearcher
+	for _, name := range settings.Backends {
+		switch name {
+		case "google":
+			if settings.ApiKey == "" || settings.Cx == "" {
+				continue
+			}
+			fallback = append(fallback, GoogleSearcher{
+				ApiKey:   settings.ApiKey,
+				Cx:       settings.Cx,
+				Endpoint: settings.GoogleEndpoint,
+				Env:      env,
+			})
+		case "site":
+			sites := DefaultSiteSearches()
+			for site, s := range settings.Sites {
+				sites[site] = s
+			}
+			fallback = append(fallback, SiteSearcher{Sites: sites, Env: env})
+		case "duckduckgo":
+			fallback = append(fallback, DuckDuckGoSearcher{Endpoint: settings.DuckDuckGoEndpoint, Env: env})
+		default:
+			return nil, fmt.Errorf("unknown search backend: %s", name)
+		}
+	}
+	if len(fallback) == 0 && len(settings.Backends) > 0 {
+		return nil, errors.New("no usable search backend: google needs api_key and cx in the config file, BOOK_GOOGLE_API_KEY and BOOK_GOOGLE_CX or -api-key-file and -cx")
+	}
+	return fallback, nil
+}
+
+// FallbackSearcher tries its searchers in order until one of them answers
+// without an error, so an exhausted quota degrades to the next backend.
+type FallbackSearcher []Searcher
+
+func (FallbackSearcher) Name() string { return "fallback" }
+
+func (fallback FallbackSearcher) Search(ctx context.Context, query string, site string, n int) ([]string, error) {
+	var errs []error
+	for _, s := range fallback {
+		links, err := s.Search(ctx, query, site, n)
+		if err == nil {
+			return links, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no search backend configured")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// GoogleSearcher uses Google Custom Search. Endpoint replaces the API
+// address, e.g. with a local stand-in. Requests go through Env when it is
+// set, so that answers are cached.
+type GoogleSearcher struct {
+	ApiKey   string
+	Cx       string
+	Endpoint string
+	Env      *Env
+}
+
+func (GoogleSearcher) Name() string { return "google" }
+
+func (google GoogleSearcher) Search(ctx context.Context, query string, site string, n int) ([]string, error) {
+	options := []option.ClientOption{option.WithAPIKey(google.ApiKey)}
+	if google.Env != nil {
+		// a custom client drops the key option, so the key is added here
+		options = []option.ClientOption{option.WithHTTPClient(&http.Client{
+			Transport: &transport.APIKey{Key: google.ApiKey, Transport: google.Env.RoundTripper(ctx)},
+		})}
+	}
+	if google.Endpoint != "" {
+		options = append(options, option.WithEndpoint(google.Endpoint))
+	}
+	svc, err := customsearch.NewService(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create custom search service: %w", err)
+	}
+
+	resp, err := svc.Cse.List().Cx(google.Cx).Num(int64(n)).SiteSearch(site).ExactTerms(query).Context(ctx).Do()
+	if err != nil {
+		return nil, googleError(err)
+	}
+
+	if len(resp.Items) == 0 {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		resp, err = svc.Cse.List().Cx(google.Cx).Num(int64(n)).SiteSearch(site).Q(query).Context(ctx).Do()
+		if err != nil {
+			return nil, googleError(err)
+		}
+	}
+
+	var urls []string
+	for _, item := range resp.Items {
+		urls = append(urls, item.Link)
+	}
+	return urls, nil
+}
+
+func googleError(err error) error {
+	var e *googleapi.Error
+	if errors.As(err, &e) && e.Code == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+	}
+	if errors.As(err, &e) && e.Code == http.StatusForbidden {
+		for _, item := range e.Errors {
+			if strings.Contains(item.Reason, "Limit") || strings.Contains(item.Reason, "quota") {
+				return fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+			}
+		}
+	}
+	return err
+}
+
+// SiteSearch describes a store's own search page: the URL with %s standing
+// for the query and the selector of the links to results.
+type SiteSearch struct {
+	Url   string `json:"url"`
+	Links string `json:"links"`
+}
+
+// DefaultSiteSearches returns the search pages of the built-in sites by
+// domain.
+func DefaultSiteSearches() map[string]SiteSearch {
+	searches := make(map[string]SiteSearch)
+	for _, site := range BuiltinSites() {
+		if site.SiteSearch != nil {
+			searches[site.Domains[0]] = *site.SiteSearch
+		}
+	}
+	return searches
+}
+
+// SiteSearcher uses the search pages of the stores themselves.
+type SiteSearcher struct {
+	Sites map[string]SiteSearch
+	Env   *Env
+}
+
+func (SiteSearcher) Name() string { return "site" }
+
+func (searcher SiteSearcher) Search(ctx context.Context, query string, site string, n int) ([]string, error) {
+	host, path, _ := strings.Cut(site, "/")
+	search, ok := searcher.Sites[host]
+	if !ok {
+		return nil, fmt.Errorf("no search page known for %s", host)
+	}
+	page := fmt.Sprintf(search.Url, escapeQuery(search.Url, query))
+	return scrapeLinks(ctx, searcher.Env, page, search.Links, "/"+path, n)
+}
+
+// escapeQuery escapes the query for where %s stands in the search URL: as
+// a query parameter after "?", otherwise as a path segment.
+func escapeQuery(template string, query string) string {
+	if q := strings.Index(template, "?"); q >= 0 && q < strings.Index(template, "%s") {
+		return url.QueryEscape(query)
+	}
+	return url.PathEscape(query)
+}
+
+// DuckDuckGoSearcher uses the HTML version of DuckDuckGo. Endpoint replaces
+// its address, e.g. with a local stand-in.
+type DuckDuckGoSearcher struct {
+	Endpoint string
+	Env      *Env
+}
+
+func (DuckDuckGoSearcher) Name() string { return "duckduckgo" }
+
+func (ddg DuckDuckGoSearcher) Search(ctx context.Context, query string, site string, n int) ([]string, error) {
+	endpoint := ddg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://html.duckduckgo.com/html/"
+	}
+	page := endpoint + "?q=" + url.QueryEscape("site:"+site+" "+query)
+	links, err := scrapeLinks(ctx, ddg.Env, page, "a.result__a[href]", "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// results link through a redirect carrying the target in uddg
+	var found []string
+	for _, link := range links {
+		if u, err := url.Parse(link); err == nil && u.Query().Get("uddg") != "" {
+			link = u.Query().Get("uddg")
+		}
+		if strings.Contains(link, site) {
+			found = append(found, link)
+		}
+		if len(found) == n {
+			break
+		}
+	}
+	return found, nil
+}
+
+// scrapeLinks collects up to n distinct absolute links matched by the
+// selector whose path starts with prefix. No limit applies when n is 0.
+func scrapeLinks(ctx context.Context, env *Env, page string, selector string, prefix string, n int) ([]string, error) {
+	u, err := url.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	c := env.NewCollector(ctx, u.Host)
+
+	var links []string
+	var visitErr error
+	seen := make(map[string]bool)
+	c.OnHTML(selector, func(e *colly.HTMLElement) {
+		link := e.Request.AbsoluteURL(e.Attr("href"))
+		l, err := url.Parse(link)
+		if link == "" || err != nil || !strings.HasPrefix(l.Path, prefix) || seen[link] {
+			return
+		}
+		if n == 0 || len(links) < n {
+			seen[link] = true
+			links = append(links, link)
+		}
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		visitErr = fmt.Errorf("%s: %w", page, err)
+	})
+
+	if err := c.Visit(page); err != nil && visitErr == nil {
+		visitErr = err
+	}
+	return links, visitErr
+}
+
+// StaticSearcher answers from a fixed table of queries to links, standing
+// in for a real backend when there is no network.
+type StaticSearcher map[string][]string
+
+func (StaticSearcher) Name() string { return "static" }
+
+func (static StaticSearcher) Search(ctx context.Context, query string, site string, n int) ([]string, error) {
+	var links []string
+	for _, link := range static[query] {
+		if strings.Contains(link, site) && (n == 0 || len(links) < n) {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
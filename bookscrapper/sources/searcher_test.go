@@ -0,0 +1,49 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEscapeQuery(t *testing.T) {
+	tests := []struct {
+		template string
+		query    string
+		want     string
+	}{
+		{"https://www.labirint.ru/search/%s/?stype=0", "мастер и маргарита", "%D0%BC%D0%B0%D1%81%D1%82%D0%B5%D1%80%20%D0%B8%20%D0%BC%D0%B0%D1%80%D0%B3%D0%B0%D1%80%D0%B8%D1%82%D0%B0"},
+		{"https://www.labirint.ru/search/%s/?stype=0", "a/b?c", "a%2Fb%3Fc"},
+		{"https://www.goodreads.com/search?q=%s", "Dune & Messiah", "Dune+%26+Messiah"},
+		{"https://www.ozon.ru/search/?text=%s&category=16500", "c++ 20", "c%2B%2B+20"},
+	}
+	for _, test := range tests {
+		if got := escapeQuery(test.template, test.query); got != test.want {
+			t.Errorf("%s with %q: got %q, want %q", test.template, test.query, got, test.want)
+		}
+	}
+}
+
+func TestFallbackAfterQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"code": 429, "message": "Quota exceeded", "errors": [{"reason": "rateLimitExceeded"}]}}`))
+	}))
+	defer server.Close()
+
+	google := GoogleSearcher{ApiKey: "key", Cx: "cx", Endpoint: server.URL + "/"}
+	if _, err := google.Search(context.Background(), "Dune", "goodreads.com", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("got %v, want ErrQuotaExceeded", err)
+	}
+
+	link := "https://www.goodreads.com/book/show/44767458-dune"
+	fallback := FallbackSearcher{google, StaticSearcher{"Dune": {link}}}
+	links, err := fallback.Search(context.Background(), "Dune", "goodreads.com", 1)
+	if err != nil || strings.Join(links, " ") != link {
+		t.Errorf("got %v, %v", links, err)
+	}
+}
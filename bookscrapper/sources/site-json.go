@@ -0,0 +1,107 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is a step of the JSON path of a field rule. Steps are separated
+// by dots: "key" takes the key of an object, "key[]" every item of the
+// array under the key and "key[name=value]" the items whose name is value,
+// e.g. "characteristics[].short[key=ISBN].values[].text". A key before the
+// brackets may be left out to go through an array at hand, and a single
+// object is taken for an array of one item, as JSON-LD often has it.
+type jsonStep struct {
+	key    string
+	each   bool
+	field  string
+	equals string
+}
+
+var jsonStepRe = regexp.MustCompile(`^([^\[\]=]*)(?:\[(?:([^\[\]=]+)=([^\[\]]*))?\])?$`)
+
+func compileJsonPath(path string) ([]jsonStep, error) {
+	var steps []jsonStep
+	for _, part := range strings.Split(path, ".") {
+		m := jsonStepRe.FindStringSubmatch(part)
+		if m == nil || part == "" {
+			return nil, fmt.Errorf("bad json path %q", path)
+		}
+		steps = append(steps, jsonStep{
+			key:    m[1],
+			each:   strings.HasSuffix(part, "]"),
+			field:  m[2],
+			equals: m[3],
+		})
+	}
+	return steps, nil
+}
+
+// jsonValues returns the strings, numbers and booleans found at the path
+// in the JSON text. Text that is not JSON has no values.
+func jsonValues(text string, steps []jsonStep) []string {
+	d := json.NewDecoder(strings.NewReader(text))
+	d.UseNumber()
+	var root interface{}
+	if err := d.Decode(&root); err != nil {
+		return nil
+	}
+
+	nodes := []interface{}{root}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			if step.key != "" {
+				obj, ok := node.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				node = obj[step.key]
+			}
+			if !step.each {
+				next = append(next, node)
+				continue
+			}
+			items, ok := node.([]interface{})
+			if !ok {
+				items = []interface{}{node}
+			}
+			for _, item := range items {
+				if step.field == "" || jsonText(jsonField(item, step.field)) == step.equals {
+					next = append(next, item)
+				}
+			}
+		}
+		nodes = next
+	}
+
+	var values []string
+	for _, node := range nodes {
+		if text := jsonText(node); text != "" {
+			values = append(values, text)
+		}
+	}
+	return values
+}
+
+func jsonField(node interface{}, key string) interface{} {
+	if obj, ok := node.(map[string]interface{}); ok {
+		return obj[key]
+	}
+	return nil
+}
+
+func jsonText(node interface{}) string {
+	switch v := node.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -0,0 +1,520 @@
+package sources
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gocolly/colly"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Site describes a store so that it can be scraped without Go code: where
+// its pages are, how to search it and where each field of the book is
+// found on a book page. Sites are written as JSON, see sites/ for the
+// built-in ones.
+type Site struct {
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+	// Hosts are the hosts book pages are fetched from; by default every
+	// domain with and without "www.".
+	Hosts []string `json:"hosts,omitempty"`
+	// Search lists what the searcher looks for links on, in order.
+	Search []SiteQuery `json:"search"`
+	// SiteSearch is the store's own search page, for the "site" backend.
+	SiteSearch *SiteSearch `json:"site_search,omitempty"`
+	// CutAt drops the end of book links from this text on, e.g. from the
+	// path of the reader page of a book.
+	CutAt string `json:"cut_at,omitempty"`
+	// Expected and KnownLinks are checked by book doctor, see Checkable.
+	Expected   []string    `json:"expected"`
+	KnownLinks []string    `json:"known_links"`
+	Fields     []FieldRule `json:"fields"`
+}
+
+// SiteQuery is a site given to the searcher, e.g. "labirint.ru/books",
+// with the number of links wanted from it.
+type SiteQuery struct {
+	Site  string `json:"site"`
+	Count int    `json:"count"`
+}
+
+// FieldRule tells where a field of the book is found on its page. Every
+// element matching Selector gives a value: its text, its attribute Attr or
+// the text of its child Child. Regex, Json and then Process refine the
+// value, and empty values are dropped.
+//
+// Field is the name of a Book field holding a string or a list of people,
+// "Genres", "Tags", "Rating" for the rating on this site, or "Contributors"
+// for people whose role depends on Roles.
+type FieldRule struct {
+	Field    string `json:"field"`
+	Selector string `json:"selector,omitempty"`
+	// Body matches Regex against the raw page rather than an element.
+	Body bool `json:"body,omitempty"`
+	// When skips the elements that do not pass the condition.
+	When *Condition `json:"when,omitempty"`
+	// Each takes a value from every element matching it inside the
+	// matched one.
+	Each  string `json:"each,omitempty"`
+	Child string `json:"child,omitempty"`
+	Attr  string `json:"attr,omitempty"`
+	// Regex keeps its first group, or the whole match if it has none.
+	// Values it does not match are dropped.
+	Regex string `json:"regex,omitempty"`
+	// Json reads the value as JSON, e.g. the state a page embeds in a
+	// script, and takes the values at the path, see jsonStep.
+	Json string `json:"json,omitempty"`
+	// Process lists the steps applied to values in order: "trim",
+	// "unescape", "year", "remove_num_prefix", "hyphenate_isbn",
+	// "trim_prefix:<text>", "trim_suffix:<text>" and "cut_at:<text>", which
+	// drops the value from the text on.
+	Process []string `json:"process,omitempty"`
+	// First keeps the value found first instead of the last one; for lists
+	// it adds nothing once the list has an item.
+	First bool `json:"first,omitempty"`
+	// Names is "first_last" for people written first name first, the
+	// default being "last_first".
+	Names string `json:"names,omitempty"`
+	// Roles maps a prefix of the matched element's text to the field of
+	// the people it introduces, e.g. "Художник: " to "Painters".
+	Roles map[string]string `json:"roles,omitempty"`
+	// NoTranslate keeps genres and tags as they are found.
+	NoTranslate bool `json:"no_translate,omitempty"`
+}
+
+// Condition tests the text of an element, or of its child Child. The text
+// is trimmed before comparing it with Equals.
+type Condition struct {
+	Child    string `json:"child,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Contains string `json:"contains,omitempty"`
+	Equals   string `json:"equals,omitempty"`
+}
+
+func (cond *Condition) test(e *colly.HTMLElement) bool {
+	text := e.Text
+	if cond.Child != "" {
+		text = e.ChildText(cond.Child)
+	}
+	return strings.HasPrefix(text, cond.Prefix) &&
+		strings.Contains(text, cond.Contains) &&
+		(cond.Equals == "" || strings.TrimSpace(text) == cond.Equals)
+}
+
+//go:embed sites/*.json
+var builtinSites embed.FS
+
+// BuiltinSites returns the definitions of the sites the scrapper comes
+// with, sorted by name.
+func BuiltinSites() []Site {
+	entries, err := builtinSites.ReadDir("sites")
+	if err != nil {
+		panic(err)
+	}
+	var sites []Site
+	for _, entry := range entries {
+		content, err := builtinSites.ReadFile(path.Join("sites", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		site, err := ParseSite(content)
+		if err != nil {
+			panic(entry.Name() + ": " + err.Error())
+		}
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return sites[i].Name < sites[j].Name
+	})
+	return sites
+}
+
+// LoadSites reads the site definitions in the *.json files of the
+// directory. A missing directory holds no sites.
+func LoadSites(dir string) ([]Site, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var sites []Site
+	for _, p := range paths {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		site, err := ParseSite(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+// ParseSite reads a site definition and checks that it can be used.
+func ParseSite(content []byte) (Site, error) {
+	var site Site
+	if err := json.Unmarshal(content, &site); err != nil {
+		return site, err
+	}
+	_, err := compileSite(&site)
+	return site, err
+}
+
+// SiteSource scrapes a store following its site definition.
+type SiteSource struct {
+	Site  Site
+	Env   *Env
+	rules []siteRule
+}
+
+type siteRule struct {
+	*FieldRule
+	// index is the position of the rule in the site definition.
+	index int
+	re    *regexp.Regexp
+	json  []jsonStep
+}
+
+// NewSiteSource returns the source for the site, or an error when the
+// definition is wrong.
+func NewSiteSource(site Site, env *Env) (*SiteSource, error) {
+	rules, err := compileSite(&site)
+	if err != nil {
+		return nil, err
+	}
+	return &SiteSource{Site: site, Env: env, rules: rules}, nil
+}
+
+var processSteps = map[string]func(s string, arg string) string{
+	"trim":        func(s string, _ string) string { return strings.TrimSpace(s) },
+	"trim_prefix": strings.TrimPrefix,
+	"trim_suffix": strings.TrimSuffix,
+	"cut_at": func(s string, sep string) string {
+		s, _, _ = strings.Cut(s, sep)
+		return s
+	},
+	"unescape": func(s string, _ string) string {
+		s, _ = url.QueryUnescape(s)
+		return s
+	},
+	"year": func(s string, _ string) string {
+		return yearRe.FindString(s)
+	},
+	"remove_num_prefix": func(s string, _ string) string { return RemoveNumPrefix(s) },
+	"hyphenate_isbn": func(s string, _ string) string {
+		if len(s) != 13 {
+			return ""
+		}
+		return s[0:3] + "-" + s[3:4] + "-" + s[4:9] + "-" + s[9:12] + "-" + s[12:13]
+	},
+}
+
+var yearRe = regexp.MustCompile("[0-9][0-9][0-9][0-9]")
+
+var personType = reflect.TypeOf([]model.Person(nil))
+
+// bookField tells whether a rule can fill the named field and whether it
+// holds people.
+func bookField(name string) (ok bool, people bool) {
+	switch name {
+	case "Genres", "Tags", "Rating":
+		return true, false
+	}
+	field, ok := reflect.TypeOf(model.Book{}).FieldByName(name)
+	if !ok {
+		return false, false
+	}
+	return field.Type.Kind() == reflect.String || field.Type == personType, field.Type == personType
+}
+
+func compileSite(site *Site) ([]siteRule, error) {
+	if site.Name == "" || len(site.Domains) == 0 {
+		return nil, fmt.Errorf("site needs a name and domains")
+	}
+	if len(site.Hosts) == 0 {
+		for _, domain := range site.Domains {
+			site.Hosts = append(site.Hosts, domain, "www."+domain)
+		}
+	}
+	for _, name := range site.Expected {
+		if _, ok := reflect.TypeOf(model.Book{}).FieldByName(name); !ok {
+			return nil, fmt.Errorf("%s: unknown expected field %q", site.Name, name)
+		}
+	}
+
+	var rules []siteRule
+	for i := range site.Fields {
+		rule := siteRule{FieldRule: &site.Fields[i], index: i}
+		where := fmt.Sprintf("%s: rule %d", site.Name, i+1)
+		if rule.Field == "Contributors" {
+			if len(rule.Roles) == 0 {
+				return nil, fmt.Errorf("%s: contributors need roles", where)
+			}
+			for _, field := range rule.Roles {
+				if _, people := bookField(field); !people {
+					return nil, fmt.Errorf("%s: %q does not hold people", where, field)
+				}
+			}
+		} else if ok, _ := bookField(rule.Field); !ok {
+			return nil, fmt.Errorf("%s: unknown field %q", where, rule.Field)
+		}
+		if rule.Names != "" && rule.Names != "first_last" && rule.Names != "last_first" {
+			return nil, fmt.Errorf("%s: unknown names order %q", where, rule.Names)
+		}
+		if rule.Body == (rule.Selector != "") {
+			return nil, fmt.Errorf("%s: needs either a selector or body", where)
+		}
+		if rule.Body && rule.Regex == "" {
+			return nil, fmt.Errorf("%s: body needs a regex", where)
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			rule.re = re
+		}
+		if rule.Json != "" {
+			steps, err := compileJsonPath(rule.Json)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			rule.json = steps
+		}
+		for _, step := range rule.Process {
+			name, _, _ := strings.Cut(step, ":")
+			if processSteps[name] == nil {
+				return nil, fmt.Errorf("%s: unknown step %q", where, step)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (source *SiteSource) Name() string      { return source.Site.Name }
+func (source *SiteSource) Domains() []string { return source.Site.Domains }
+
+func (source *SiteSource) ExpectedFields() []string { return source.Site.Expected }
+func (source *SiteSource) KnownLinks() []string     { return source.Site.KnownLinks }
+
+func (source *SiteSource) Search(ctx context.Context, query string) ([]string, error) {
+	var links []string
+	for _, q := range source.Site.Search {
+		more, err := source.Env.Searcher.Search(ctx, query, q.Site, q.Count)
+		links = append(links, more...)
+		if err != nil {
+			return links, err
+		}
+	}
+	return links, nil
+}
+
+func (source *SiteSource) owns(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range source.Site.Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (source *SiteSource) Fetch(ctx context.Context, link string) (model.Book, error) {
+	book, _, err := source.fetch(ctx, link)
+	return book, err
+}
+
+// UnmatchedRules fetches the page and describes the rules of the site
+// definition that found no value on it, along with the book found.
+func (source *SiteSource) UnmatchedRules(ctx context.Context, link string) (model.Book, []string, error) {
+	book, matched, err := source.fetch(ctx, link)
+	if matched == nil {
+		return book, nil, err
+	}
+	var unmatched []string
+	for _, rule := range source.rules {
+		if !matched[rule.index] {
+			unmatched = append(unmatched, rule.String())
+		}
+	}
+	return book, unmatched, err
+}
+
+// String describes the rule by its place in the definition, its field and
+// where it looks for the value.
+func (rule siteRule) String() string {
+	where := "selector " + rule.Selector
+	if rule.Body {
+		where = "body regex " + rule.Regex
+	}
+	if rule.Json != "" {
+		where += ", json " + rule.Json
+	}
+	return fmt.Sprintf("rule %d (%s): %s", rule.index+1, rule.Field, where)
+}
+
+// fetch scrapes the page and tells which rules stored a value. It returns
+// no matches when the page was not fetched.
+func (source *SiteSource) fetch(ctx context.Context, link string) (model.Book, []bool, error) {
+	book := model.NewBook()
+	name := source.Site.Name
+	if !source.owns(link) {
+		err := fmt.Errorf("%w: not a %s link", ErrNotFound, source.Site.Domains[0])
+		return book, nil, sourceError(name, link, err)
+	}
+	if cut := source.Site.CutAt; cut != "" {
+		if pos := strings.LastIndex(link, cut); pos > 0 {
+			link = link[0:pos]
+		}
+	}
+	book.SetSourceUrl(name, link)
+
+	matched := make([]bool, len(source.rules))
+	c := source.Env.NewCollector(ctx, source.Site.Hosts...)
+	for _, rule := range source.rules {
+		rule := rule
+		if rule.Body {
+			c.OnResponse(func(r *colly.Response) {
+				matched[rule.index] = source.apply(&book, rule, rule.Field, string(r.Body)) || matched[rule.index]
+			})
+			continue
+		}
+		c.OnHTML(rule.Selector, func(e *colly.HTMLElement) {
+			if rule.When != nil && !rule.When.test(e) {
+				return
+			}
+			field := rule.Field
+			if rule.Roles != nil {
+				field = ""
+				for prefix, f := range rule.Roles {
+					if strings.HasPrefix(e.Text, prefix) {
+						field = f
+					}
+				}
+				if field == "" {
+					return
+				}
+			}
+			if rule.Each == "" {
+				matched[rule.index] = source.apply(&book, rule, field, rule.value(e)) || matched[rule.index]
+				return
+			}
+			e.ForEach(rule.Each, func(i int, child *colly.HTMLElement) {
+				matched[rule.index] = source.apply(&book, rule, field, rule.value(child)) || matched[rule.index]
+			})
+		})
+	}
+
+	err := visitPage(c, link)
+	book.InitFileName()
+	if err != nil {
+		matched = nil
+	}
+	return book, matched, sourceError(name, link, source.Env.checkParsed(source, &book, err))
+}
+
+func (rule siteRule) value(e *colly.HTMLElement) string {
+	switch {
+	case rule.Child != "":
+		return e.ChildText(rule.Child)
+	case rule.Attr != "":
+		return e.Attr(rule.Attr)
+	}
+	return e.Text
+}
+
+// apply refines the value with the regex, the JSON path and the
+// processing steps of the rule and stores it in the field. It tells
+// whether anything was left to store.
+func (source *SiteSource) apply(book *model.Book, rule siteRule, field string, value string) bool {
+	if rule.re != nil {
+		m := rule.re.FindStringSubmatch(value)
+		switch {
+		case m == nil:
+			return false
+		case len(m) > 1:
+			value = m[1]
+		default:
+			value = m[0]
+		}
+	}
+	if rule.json == nil {
+		return source.store(book, rule, field, value)
+	}
+	stored := false
+	for _, v := range jsonValues(value, rule.json) {
+		stored = source.store(book, rule, field, v) || stored
+	}
+	return stored
+}
+
+func (source *SiteSource) store(book *model.Book, rule siteRule, field string, value string) bool {
+	for _, step := range rule.Process {
+		name, arg, _ := strings.Cut(step, ":")
+		value = processSteps[name](value, arg)
+	}
+	if value == "" {
+		return false
+	}
+
+	switch field {
+	case "Genres":
+		if rule.NoTranslate {
+			book.Genres[strings.ToLower(value)] = ""
+		} else {
+			source.Env.appendGenre(book, value)
+		}
+		return true
+	case "Tags":
+		if rule.NoTranslate {
+			book.Tags[strings.ToLower(value)] = ""
+		} else {
+			source.Env.appendTag(book, value)
+		}
+		return true
+	case "Rating":
+		if !rule.First || book.Ratings[source.Site.Name] == "" {
+			book.SetRating(source.Site.Name, value)
+		}
+		return true
+	}
+
+	v := reflect.ValueOf(book).Elem().FieldByName(field)
+	if v.Type() == personType {
+		if !rule.First || v.Len() == 0 {
+			person := model.ParsePerson(value, rule.Names == "first_last")
+			v.Set(reflect.Append(v, reflect.ValueOf(person)))
+		}
+	} else if !rule.First || v.String() == "" {
+		v.SetString(value)
+	}
+	return true
+}
+
+// RemoveNumPrefix drops the place in a rating, e.g. "№3 в " before a
+// genre.
+func RemoveNumPrefix(text string) string {
+	s := text
+	re, _ := regexp.Compile(`№\d* в (.*)`)
+	m := re.FindSubmatch([]byte(s))
+	if m != nil {
+		s = strings.TrimSpace(string(m[1]))
+	}
+	return s
+}
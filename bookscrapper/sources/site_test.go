@@ -0,0 +1,143 @@
+package sources
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseSiteErrors(t *testing.T) {
+	tests := []struct {
+		site string
+		err  string
+	}{
+		{`{"domains": ["example.org"]}`, "needs a name"},
+		{`{"name": "x", "domains": ["example.org"], "expected": ["Title"]}`, "unknown expected field"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Title", "selector": "h1"}]}`, "unknown field"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Name"}]}`, "either a selector or body"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Name", "body": true}]}`, "body needs a regex"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Name", "selector": "h1", "regex": "("}]}`, "missing closing"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Name", "selector": "h1", "process": ["upper"]}]}`, "unknown step"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Contributors", "selector": "p"}]}`, "need roles"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Contributors", "selector": "p", "roles": {"By ": "Year"}}]}`, "does not hold people"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Name", "selector": "h1", "json": "a[b"}]}`, "bad json path"},
+		{`{"name": "x", "domains": ["example.org"], "fields": [{"field": "Authors", "selector": "a", "names": "first"}]}`, "unknown names order"},
+	}
+	for _, test := range tests {
+		_, err := ParseSite([]byte(test.site))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.site, err, test.err)
+		}
+	}
+}
+
+func TestBuiltinSites(t *testing.T) {
+	sites := BuiltinSites()
+	if len(sites) < 4 {
+		t.Fatalf("got %d built-in sites", len(sites))
+	}
+	for _, site := range sites {
+		if len(site.Hosts) == 0 || len(site.Search) == 0 || len(site.Expected) == 0 {
+			t.Errorf("%s: incomplete definition", site.Name)
+		}
+	}
+}
+
+func TestJsonValues(t *testing.T) {
+	const state = `{
+		"name": "Хоббит",
+		"pages": 320,
+		"author": {"name": "Толкин"},
+		"characteristics": [
+			{"short": [{"key": "ISBN", "values": [{"text": "978-5-17-085929-8"}]}]},
+			{"short": [{"key": "Genre", "values": [{"text": "Фэнтези"}, {"text": "Сказка"}]}]}
+		]
+	}`
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"name", []string{"Хоббит"}},
+		{"pages", []string{"320"}},
+		{"author[].name", []string{"Толкин"}},
+		{"characteristics[].short[key=Genre].values[].text", []string{"Фэнтези", "Сказка"}},
+		{"characteristics[].short[key=Series].values[].text", nil},
+		{"author", nil},
+	}
+	for _, test := range tests {
+		steps, err := compileJsonPath(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := jsonValues(state, steps)
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s: got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestSiteSearchPage(t *testing.T) {
+	env := newMirror(t)
+	env.Searcher = SiteSearcher{Sites: DefaultSiteSearches(), Env: env}
+	source, err := NewSiteSource(siteNamed(t, "chitaigorod"), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links, err := source.Search(context.Background(), "мастер и маргарита")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://www.chitai-gorod.ru/product/master-i-margarita-2897538",
+		"https://www.chitai-gorod.ru/product/master-i-margarita-3004925",
+	}
+	if strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", links, want)
+	}
+}
+
+func siteNamed(t *testing.T, name string) Site {
+	t.Helper()
+	for _, site := range BuiltinSites() {
+		if site.Name == name {
+			return site
+		}
+	}
+	t.Fatalf("no built-in site %s", name)
+	return Site{}
+}
+
+func TestUnmatchedRules(t *testing.T) {
+	site, err := ParseSite([]byte(`{
+		"name": "example", "domains": ["books.example.com"],
+		"fields": [
+			{"field": "Name", "selector": "h1"},
+			{"field": "Publisher", "selector": "span.publisher"},
+			{"field": "Isbn", "selector": "script[type=\"application/ld+json\"]", "json": "@graph[].isbn"},
+			{"field": "Year", "selector": "title", "regex": "(\\d{4})"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewSiteSource(site, newMirror(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, unmatched, err := source.UnmatchedRules(context.Background(), "https://books.example.com/book/jsonld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"rule 2 (Publisher): selector span.publisher",
+		"rule 4 (Year): selector title",
+	}
+	if strings.Join(unmatched, "\n") != strings.Join(want, "\n") || book.Isbn != "9780441478125" {
+		t.Errorf("got %q, ISBN %q, want %q", unmatched, book.Isbn, want)
+	}
+
+	_, unmatched, err = source.UnmatchedRules(context.Background(), "https://books.example.com/book/missing")
+	if err == nil || unmatched != nil {
+		t.Errorf("got %q, %v for a missing page", unmatched, err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package sources searches bookstores and catalogues and scrapes book pages
+// from them.
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+)
+
+// Source is a bookstore or catalogue the scrapper knows how to search and
+// read book pages from.
+type Source interface {
+	// Name is the short identifier used in configuration, e.g. "labirint".
+	Name() string
+	// Domains lists the hosts whose pages the source can fetch.
+	Domains() []string
+	// Search returns links to book pages matching the query.
+	Search(ctx context.Context, query string) ([]string, error)
+	// Fetch scrapes the book page at the given link.
+	Fetch(ctx context.Context, link string) (model.Book, error)
+}
+
+// BookSearcher is implemented by sources that can search by title and
+// author separately, which finds the same book more reliably than a query
+// joining them.
+type BookSearcher interface {
+	SearchBook(ctx context.Context, title string, author string) ([]string, error)
+}
+
+// Registry keeps the known sources and the order they are tried in for
+// every query language.
+type Registry struct {
+	sources map[string]Source
+	order   map[string][]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		sources: make(map[string]Source),
+		order:   make(map[string][]string),
+	}
+}
+
+// Register adds a source to the registry. Registering two sources with the
+// same name is a programming error.
+func (registry *Registry) Register(source Source) {
+	name := source.Name()
+	if _, ok := registry.sources[name]; ok {
+		panic("source registered twice: " + name)
+	}
+	registry.sources[name] = source
+}
+
+// Replace adds a source to the registry in place of the registered one
+// with the same name, if any.
+func (registry *Registry) Replace(source Source) {
+	registry.sources[source.Name()] = source
+}
+
+func (registry *Registry) Get(name string) Source {
+	return registry.sources[name]
+}
+
+// Names returns the names of all registered sources in alphabetical order.
+func (registry *Registry) Names() []string {
+	var names []string
+	for name := range registry.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetOrder enables the named sources for the language, in the given order.
+// Sources not listed are disabled for that language.
+func (registry *Registry) SetOrder(lang string, names []string) error {
+	for _, name := range names {
+		if _, ok := registry.sources[name]; !ok {
+			return fmt.Errorf("unknown source: %s", name)
+		}
+	}
+	registry.order[lang] = names
+	return nil
+}
+
+// Enabled returns the sources enabled for the language in the order they
+// should be tried.
+func (registry *Registry) Enabled(lang string) []Source {
+	var sources []Source
+	for _, name := range registry.order[lang] {
+		sources = append(sources, registry.sources[name])
+	}
+	return sources
+}
+
+// AllEnabled returns every source enabled for any language, each once.
+func (registry *Registry) AllEnabled() []Source {
+	var langs []string
+	for lang := range registry.order {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	var sources []Source
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		for _, source := range registry.Enabled(lang) {
+			if !seen[source.Name()] {
+				seen[source.Name()] = true
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// ForLink returns the source able to fetch the given link, or nil when the
+// link does not belong to any registered source.
+func (registry *Registry) ForLink(link string) Source {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, name := range registry.Names() {
+		source := registry.sources[name]
+		for _, domain := range source.Domains() {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return source
+			}
+		}
+	}
+	return nil
+}
+
+// AsLink reports whether the query is a link to a book page and returns it
+// with a scheme. Links copied without a scheme are recognized for the
+// registered sources only.
+func (registry *Registry) AsLink(query string) (string, bool) {
+	u, err := url.Parse(query)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return query, true
+	}
+	if !strings.Contains(query, " ") && registry.ForLink("https://"+query) != nil {
+		return "https://" + query, true
+	}
+	return "", false
+}
+
+// QueryLanguage guesses the language of a query the same way file names
+// and authors are classified.
+func QueryLanguage(query string) string {
+	if model.IsEnglish(query) {
+		return "en"
+	}
+	return "ru"
+}
+
+// Result is a book a source found, or the error it failed with.
+type Result struct {
+	Source string
+	Book   model.Book
+	Err    error
+}
+
+// SearchAll searches all the sources at once and fetches every book they
+// find. Books are sent as soon as they are scraped, and a failing source
+// does not stop the others: its error is sent instead. The channel is
+// closed when all sources are done or the context is cancelled.
+func SearchAll(ctx context.Context, sources []Source, query string) <-chan Result {
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+
+	fetch := func(source Source, link string) {
+		defer wg.Done()
+		mu.Lock()
+		dup := seen[link]
+		seen[link] = true
+		mu.Unlock()
+		if dup {
+			return
+		}
+		book, err := source.Fetch(ctx, link)
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+		select {
+		case results <- Result{Source: source.Name(), Book: book, Err: err}:
+		case <-ctx.Done():
+		}
+	}
+
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source Source) {
+			defer wg.Done()
+			links, err := source.Search(ctx, query)
+			if err != nil {
+				select {
+				case results <- Result{Source: source.Name(), Err: fmt.Errorf("%s: %w", source.Name(), err)}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			for _, link := range links {
+				wg.Add(1)
+				go fetch(source, link)
+			}
+		}(source)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,43 @@
+package sources
+
+import "testing"
+
+func TestAsLink(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(FantlabSource{})
+	registry.Register(OpenLibrarySource{})
+
+	tests := []struct {
+		query  string
+		link   string
+		source string
+	}{
+		{"https://fantlab.ru/work2", "https://fantlab.ru/work2", "fantlab"},
+		{"http://openlibrary.org/works/OL1W", "http://openlibrary.org/works/OL1W", "openlibrary"},
+		{"fantlab.ru/work2", "https://fantlab.ru/work2", "fantlab"},
+		{"www.FantLab.ru/work2", "https://www.FantLab.ru/work2", "fantlab"},
+		// links of other sites go to the generic source
+		{"https://books.example.com/book/1", "https://books.example.com/book/1", ""},
+		// without a scheme only the registered sources are recognized
+		{"books.example.com/book/1", "", ""},
+		{"notfantlab.ru/work2", "", ""},
+		{"ftp://fantlab.ru/work2", "", ""},
+		{"Мастер и Маргарита", "", ""},
+		{"fantlab.ru хоббит", "", ""},
+		{"9785389016866", "", ""},
+	}
+	for _, test := range tests {
+		link, ok := registry.AsLink(test.query)
+		if link != test.link || ok != (test.link != "") {
+			t.Errorf("%q: got %q, %v", test.query, link, ok)
+			continue
+		}
+		source := ""
+		if s := registry.ForLink(link); s != nil {
+			source = s.Name()
+		}
+		if ok && source != test.source {
+			t.Errorf("%q: fetched by %q, want %q", test.query, source, test.source)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package translate translates genres and tags with Google Translate and
+// remembers the translations in a JSON file.
+package translate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	/* 	"os/exec" */
+
+	"github.com/bregydoc/gtranslate"
+)
+
+/* var Translations = map[string]string{} */
+
+// Settings tell where translations are kept and which languages they are
+// between.
+type Settings struct {
+	File string `json:"file"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type TagTranslator struct {
+	mu       sync.Mutex
+	settings Settings
+	data     map[string]string
+	changed  bool
+}
+
+// Load returns a translator with the saved translations. A missing file is
+// not an error: the translator starts empty and Save creates it.
+func Load(settings Settings) (*TagTranslator, error) {
+	translator := &TagTranslator{
+		settings: settings,
+		data:     make(map[string]string),
+	}
+
+	content, err := os.ReadFile(settings.File)
+	if errors.Is(err, fs.ErrNotExist) {
+		return translator, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &translator.data); err != nil {
+		return nil, fmt.Errorf("%s: %w", settings.File, err)
+	}
+	return translator, nil
+}
+
+// Save writes the translations back to the file if any were added or
+// changed, creating its directory when needed.
+func (translator *TagTranslator) Save() error {
+	translator.mu.Lock()
+	defer translator.mu.Unlock()
+	if !translator.changed {
+		return nil
+	}
+
+	file, err := json.MarshalIndent(translator.data, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(translator.settings.File), 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(translator.settings.File, file, 0644); err != nil {
+		return err
+	}
+	translator.changed = false
+	return nil
+}
+
+// Translate returns the known translation of the query, or asks Google
+// Translate for it. The lock is not held during the request, so that the
+// sources translating their genres concurrently do not wait on each other;
+// two of them may ask for the same word, which is harmless.
+func (translator *TagTranslator) Translate(query string) (string, error) {
+	translator.mu.Lock()
+	trans := translator.data[query]
+	translator.mu.Unlock()
+	if len(trans) > 0 {
+		return trans, nil
+	}
+
+	translated, err := gtranslate.TranslateWithParams(
+		query,
+		gtranslate.TranslationParams{
+			From: translator.settings.From,
+			To:   translator.settings.To,
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("translate %q: %w", query, err)
+	}
+
+	translator.mu.Lock()
+	translator.data[query] = translated
+	translator.changed = true
+	translator.mu.Unlock()
+	return translated, nil
+}
+
+// Entries returns a copy of the known translations.
+func (translator *TagTranslator) Entries() map[string]string {
+	translator.mu.Lock()
+	defer translator.mu.Unlock()
+
+	entries := make(map[string]string, len(translator.data))
+	for k, v := range translator.data {
+		entries[k] = v
+	}
+	return entries
+}
+
+// Set fixes the translation of a genre or tag. Translating it to "#" keeps
+// it untranslated in notes.
+func (translator *TagTranslator) Set(text string, translation string) {
+	translator.mu.Lock()
+	defer translator.mu.Unlock()
+	translator.data[text] = translation
+	translator.changed = true
+}
+
+// Remove forgets the translation, so the next lookup asks Google again.
+func (translator *TagTranslator) Remove(text string) bool {
+	translator.mu.Lock()
+	defer translator.mu.Unlock()
+	_, ok := translator.data[text]
+	delete(translator.data, text)
+	translator.changed = translator.changed || ok
+	return ok
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jupy/book-scrapper/bookscrapper/match"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"github.com/jupy/book-scrapper/bookscrapper/render"
+	"github.com/jupy/book-scrapper/bookscrapper/schema"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// progress is where messages about what is going on are written. With
+// -json or -jsonl they go to stderr so that stdout holds nothing but JSON.
+var progress io.Writer = os.Stdout
+
+var searchCommand = &Command{
+	Name:  "search",
+	Args:  "<query>",
+	Short: "search the sources and write a note for the chosen book",
+	Long: `
+The query is looked for on the sources enabled for its language, or on the
+ones given with -source. The results are grouped into editions and ranked by
+relevance; pick one, or let -auto pick the best.`,
+	Run: runSearch,
+}
+
+var fetchCommand = &Command{
+	Name:  "fetch",
+	Args:  "<url>...",
+	Short: "scrape book pages and write a note for the book",
+	Long: `
+Several links to pages of the same book on different sources are merged
+into one note.`,
+	Run: runFetch,
+}
+
+var isbnCommand = &Command{
+	Name:  "isbn",
+	Args:  "<isbn>",
+	Short: "find a book by ISBN on every enabled source",
+	Run:   runIsbn,
+}
+
+var batchCommand = &Command{
+	Name:  "batch",
+	Args:  "<file>",
+	Short: "look up every line of a file without asking",
+	Long: `
+Every line is a query, one or more links or an ISBN, as for "book" without
+a command; empty lines and lines starting with # are skipped. "-" reads the
+lines from stdin. Only results relevant enough for -auto are picked, and
+existing notes are updated only with -update -yes.`,
+	Run: runBatch,
+}
+
+var updateCommand = &Command{
+	Name:  "update",
+	Args:  "<note>...",
+	Short: "refresh notes with fresh metadata, keeping what you wrote",
+	Long: `
+The book is fetched again from the sources the note links to, or searched
+for by title and author when it links to none.`,
+	Run: runUpdate,
+}
+
+var translationsCommand = &Command{
+	Name:  "translations",
+	Args:  "list [prefix] | set <text> <translation> | remove <text>...",
+	Short: "show and fix the genre and tag translations",
+	Long: `
+A text translated to "#" stays untranslated in notes. Removed translations
+are asked from Google Translate again the next time they are needed.`,
+	Run: runTranslations,
+}
+
+var vaultCommand = &Command{
+	Name:  "vault",
+	Args:  "list | update",
+	Short: "list or refresh all book notes in the output directory",
+	Run:   runVault,
+}
+
+var cacheCommand = &Command{
+	Name:  "cache",
+	Args:  "list [domain...] | purge [expired | domain...]",
+	Short: "show or clear the cached pages and search results",
+	Long: `
+Pages are kept in the cache directory and used instead of the sites until
+they expire; -offline uses them however old they are and -no-cache skips
+the cache. "purge" with no arguments clears the whole cache, "purge
+expired" only what is too old to be used online.`,
+	Run: runCache,
+}
+
+var doctorCommand = &Command{
+	Name:  "doctor",
+	Args:  "[source...]",
+	Short: "check that the sources still find everything on their pages",
+	Long: `
+Known pages of every source, or of the named ones, are fetched and the
+fields the source should have found on them but did not are reported: these
+point to selectors that stopped matching after a site changed its layout.
+For site definitions, every field rule that found nothing on a page is
+listed under it with its selector. The pages checked can be replaced with
+"doctor_links" in the config; sources with no pages to check are listed as
+unchecked.`,
+	Run: runDoctor,
+}
+
+func runLookup(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: nothing to look up", errUsage)
+	}
+	links := true
+	for _, arg := range args {
+		if _, ok := app.Scrapper.Registry.AsLink(arg); !ok {
+			links = false
+		}
+	}
+	if links {
+		return runFetch(app, args)
+	}
+	if model.IsIsbn(strings.Join(args, "")) {
+		return runIsbn(app, []string{strings.Join(args, "")})
+	}
+	return runSearch(app, args)
+}
+
+func runSearch(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no query", errUsage)
+	}
+	query := strings.Join(args, " ")
+	book, err := app.choose(query, app.Scrapper.Search)
+	if book == nil {
+		return err
+	}
+	return app.finish(book, "")
+}
+
+func runIsbn(app *App, args []string) error {
+	if len(args) != 1 || !model.IsIsbn(args[0]) {
+		return fmt.Errorf("%w: expected one valid ISBN", errUsage)
+	}
+	book, err := app.choose(args[0], app.Scrapper.LookupIsbn)
+	if book == nil {
+		return err
+	}
+	return app.finish(book, "")
+}
+
+func runFetch(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no link", errUsage)
+	}
+	var links []string
+	for _, arg := range args {
+		link, ok := app.Scrapper.Registry.AsLink(arg)
+		if !ok {
+			return fmt.Errorf("%w: not a link: %s", errUsage, arg)
+		}
+		links = append(links, link)
+	}
+
+	ctx, cancel := config.WithTimeout()
+	book, err := app.Scrapper.FetchLinks(ctx, links)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return app.finish(&book, "")
+}
+
+// choose runs the lookup for the query, lists what it finds and returns
+// the edition picked. It returns no book and no error when nothing was
+// picked from what was found. With JSON output and no -auto all the
+// results are printed and none is picked.
+func (app *App) choose(query string, lookup func(context.Context, string) <-chan sources.Result) (*model.Book, error) {
+	fmt.Fprintf(progress, "query: %s\n", query)
+	ctx, cancel := config.WithTimeout()
+	books, errs := ListBooks(lookup(ctx, query))
+	cancel()
+
+	editions := match.GroupEditions(books)
+	match.RankEditions(editions, query)
+	if config.JsonOutput() && !config.Auto {
+		list := []schema.Edition{}
+		for i := range editions {
+			list = append(list, schema.FromEdition(&editions[i], config.Merge))
+		}
+		if err := printJsonList(list); err != nil {
+			return nil, err
+		}
+	} else if book := SelectBook(editions); book != nil {
+		return book, nil
+	} else if len(editions) > 0 && config.NoInput {
+		return nil, fmt.Errorf("%w: no result for %s is relevant enough to pick", sources.ErrNotFound, query)
+	}
+	if len(errs) > 0 {
+		return nil, reported{errors.Join(errs...)}
+	}
+	if len(books) == 0 {
+		return nil, fmt.Errorf("%w: %s", sources.ErrNotFound, query)
+	}
+	return nil, nil
+}
+
+// finish fills the book in from the other sources and writes its note to
+// path, or next to the other notes when path is empty. With JSON output the
+// book is printed instead.
+func (app *App) finish(book *model.Book, path string) error {
+	if config.Enrich {
+		ctx, cancel := config.WithTimeout()
+		app.Scrapper.Enrich(ctx, book)
+		cancel()
+	}
+	if config.JsonOutput() {
+		return printJson(schema.FromBook(book))
+	}
+	if path == "" {
+		path = NotePath(book)
+	}
+	return SaveMarkdown(book, path)
+}
+
+// printJson prints the object indented with -json and on one line with
+// -jsonl.
+func printJson(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	if !config.Jsonl {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
+
+// printJsonList prints the list as an array with -json and one object per
+// line with -jsonl.
+func printJsonList[T any](list []T) error {
+	if !config.Jsonl {
+		return printJson(list)
+	}
+	for _, v := range list {
+		if err := printJson(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runBatch(app *App, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected one file", errUsage)
+	}
+	var r io.Reader = stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	config.Auto = true
+	config.NoInput = true
+	app.Scrapper.Resolver = config.Resolver()
+
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Fprintf(progress, "=======\n")
+		err := runLookup(app, strings.Fields(line))
+		if err == nil {
+			continue
+		}
+		var rep reported
+		if !errors.As(err, &rep) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", line, err)
+		}
+		errs = append(errs, err)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return reported{errors.Join(errs...)}
+	}
+	return nil
+}
+
+func runUpdate(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no note", errUsage)
+	}
+	return app.updateNotes(args, false)
+}
+
+// updateNotes refreshes the notes, going on with the rest when one fails.
+// Files that are not book notes are skipped quietly if skipOther is set.
+func (app *App) updateNotes(paths []string, skipOther bool) error {
+	config.Update = true
+
+	var errs []error
+	for _, path := range paths {
+		note, err := render.LoadNote(path)
+		if err != nil {
+			if !skipOther {
+				fmt.Fprintln(os.Stderr, err)
+				errs = append(errs, err)
+			}
+			continue
+		}
+		fmt.Fprintf(progress, "=======\nnote: %s\n", path)
+		if err = app.updateNote(&note, path); err != nil {
+			var rep reported
+			if !errors.As(err, &rep) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return reported{errors.Join(errs...)}
+	}
+	return nil
+}
+
+func (app *App) updateNote(note *render.Note, path string) error {
+	var links []string
+	for _, name := range note.Book.FoundOn() {
+		link := note.Book.SourceUrl(name)
+		generic := app.Scrapper.Fallback != nil && name == app.Scrapper.Fallback.Name()
+		if generic || app.Scrapper.Registry.ForLink(link) != nil {
+			links = append(links, link)
+		}
+	}
+
+	if len(links) == 0 {
+		query := note.Book.Name
+		if len(note.Book.Authors) > 0 {
+			query += " " + note.Book.Authors[0].LastName
+		}
+		book, err := app.choose(query, app.Scrapper.Search)
+		if book == nil {
+			return err
+		}
+		return app.finish(book, path)
+	}
+
+	ctx, cancel := config.WithTimeout()
+	book, err := app.Scrapper.FetchLinks(ctx, links)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return app.finish(&book, path)
+}
+
+func runTranslations(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no action", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		prefix := strings.ToLower(strings.Join(args[1:], " "))
+		entries := app.Translator.Entries()
+		for k := range entries {
+			if !strings.HasPrefix(k, prefix) {
+				delete(entries, k)
+			}
+		}
+		if config.JsonOutput() {
+			return printJson(entries)
+		}
+		var keys []string
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s\t%s\n", k, entries[k])
+		}
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("%w: expected the text and its translation", errUsage)
+		}
+		text := strings.ToLower(args[1])
+		translation := strings.Join(args[2:], " ")
+		if config.DryRun {
+			fmt.Printf("would translate %q as %q\n", text, translation)
+			return nil
+		}
+		app.Translator.Set(text, translation)
+	case "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: expected the texts to remove", errUsage)
+		}
+		for _, text := range args[1:] {
+			text = strings.ToLower(text)
+			if _, ok := app.Translator.Entries()[text]; !ok {
+				return fmt.Errorf("%w: no translation for %q", sources.ErrNotFound, text)
+			}
+			if config.DryRun {
+				fmt.Printf("would remove %q\n", text)
+			} else {
+				app.Translator.Remove(text)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", errUsage, args[0])
+	}
+	return nil
+}
+
+func runVault(app *App, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected list or update", errUsage)
+	}
+	paths, err := filepath.Glob(filepath.Join(config.OutputDir, "*.md"))
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		books := []schema.Book{}
+		for _, path := range paths {
+			note, err := render.LoadNote(path)
+			if err != nil {
+				continue
+			}
+			if config.JsonOutput() {
+				books = append(books, schema.FromBook(&note.Book))
+				continue
+			}
+			fmt.Printf("%s\t%s\t%s\n", note.Status, note.Book.FileName, strings.Join(note.Book.FoundOn(), ", "))
+		}
+		if config.JsonOutput() {
+			return printJsonList(books)
+		}
+		return nil
+	case "update":
+		return app.updateNotes(paths, true)
+	}
+	return fmt.Errorf("%w: unknown action %q", errUsage, args[0])
+}
+
+// cacheItem is how a cached page is listed with -json.
+type cacheItem struct {
+	Url     string    `json:"url"`
+	Size    int       `json:"size"`
+	Fetched time.Time `json:"fetched"`
+	Expired bool      `json:"expired"`
+}
+
+func runCache(app *App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: expected list or purge", errUsage)
+	}
+	cache := app.Scrapper.Env.Cache
+	if cache == nil {
+		return errors.New("the cache is disabled")
+	}
+	domains := args[1:]
+	fromDomains := func(entry *sources.CacheEntry) bool {
+		host := entry.Host()
+		for _, domain := range domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+		return len(domains) == 0
+	}
+
+	switch args[0] {
+	case "list":
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		items := []cacheItem{}
+		for i := range entries {
+			entry := &entries[i]
+			if !fromDomains(entry) {
+				continue
+			}
+			item := cacheItem{entry.Url, entry.Size, entry.Fetched, cache.Expired(entry)}
+			if config.JsonOutput() {
+				items = append(items, item)
+				continue
+			}
+			expired := ""
+			if item.Expired {
+				expired = "expired"
+			}
+			age := time.Since(item.Fetched).Round(time.Minute)
+			fmt.Printf("%s\t%d\t%s\t%s\n", age, item.Size, expired, item.Url)
+		}
+		if config.JsonOutput() {
+			return printJsonList(items)
+		}
+		return nil
+	case "purge":
+		remove := fromDomains
+		if len(domains) == 1 && domains[0] == "expired" {
+			remove = cache.Expired
+		}
+		if config.DryRun {
+			entries, err := cache.Entries()
+			if err != nil {
+				return err
+			}
+			for i := range entries {
+				if remove(&entries[i]) {
+					fmt.Printf("would remove %s\n", entries[i].Url)
+				}
+			}
+			return nil
+		}
+		n, err := cache.Purge(remove)
+		fmt.Fprintf(progress, "%d cached pages removed\n", n)
+		return err
+	}
+	return fmt.Errorf("%w: unknown action %q", errUsage, args[0])
+}
+
+// checkupItem is how a checked page is listed with -json.
+type checkupItem struct {
+	Source    string   `json:"source"`
+	Url       string   `json:"url"`
+	Missing   []string `json:"missing"`
+	Unmatched []string `json:"unmatched_rules"`
+	// Unchecked is set for a source that has no pages to check.
+	Unchecked bool   `json:"unchecked,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func runDoctor(app *App, args []string) error {
+	names := args
+	if len(names) == 0 {
+		names = app.Scrapper.Registry.Names()
+	}
+	// the checkups tell about missing fields themselves
+	app.Scrapper.Env.Logf = nil
+
+	items := []checkupItem{}
+	var errs []error
+	for _, name := range names {
+		source := app.Scrapper.Registry.Get(name)
+		if source == nil {
+			return fmt.Errorf("%w: unknown source %q", errUsage, name)
+		}
+		ctx, cancel := config.WithTimeout()
+		checkups := sources.Check(ctx, source, config.DoctorLinks[name])
+		cancel()
+		if len(checkups) == 0 {
+			fmt.Fprintf(progress, "%s: no known pages to check, name some with \"doctor_links\" in the config\n", name)
+			if config.JsonOutput() {
+				items = append(items, checkupItem{Source: name, Missing: []string{}, Unmatched: []string{}, Unchecked: true})
+			} else {
+				fmt.Printf("%s\t-\tunchecked\n", name)
+			}
+		}
+		for _, checkup := range checkups {
+			item := checkupItem{Source: checkup.Source, Url: checkup.Url, Missing: checkup.Missing, Unmatched: checkup.Unmatched}
+			if item.Missing == nil {
+				item.Missing = []string{}
+			}
+			if item.Unmatched == nil {
+				item.Unmatched = []string{}
+			}
+			status := "ok"
+			if checkup.Err != nil {
+				item.Error = checkup.Err.Error()
+				status = "failed: " + item.Error
+				errs = append(errs, checkup.Err)
+			} else if len(checkup.Missing) > 0 {
+				status = "nothing found for " + strings.Join(checkup.Missing, ", ")
+				errs = append(errs, fmt.Errorf("%w: %s", sources.ErrIncomplete, checkup.Url))
+			}
+			if config.JsonOutput() {
+				items = append(items, item)
+			} else {
+				fmt.Printf("%s\t%s\t%s\n", checkup.Source, checkup.Url, status)
+				for _, rule := range checkup.Unmatched {
+					fmt.Printf("\tno match: %s\n", rule)
+				}
+			}
+		}
+	}
+	if config.JsonOutput() {
+		if err := printJsonList(items); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return reported{errors.Join(errs...)}
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jupy/book-scrapper/bookscrapper"
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+	"github.com/jupy/book-scrapper/bookscrapper/translate"
+)
+
+// Config holds everything that differs between setups. It is read from
+// config.json in the user config directory, then overridden by BOOK_*
+// environment variables and finally by command line flags.
+type Config struct {
+	Search      sources.SearchSettings `json:"search"`
+	OutputDir   string                 `json:"output_dir"`
+	LibraryRoot string                 `json:"library_root"`
+	Template    string                 `json:"template"`
+	Sources     map[string][]string    `json:"sources"`
+	Translator  translate.Settings     `json:"translator"`
+	// Enrich looks the chosen book up on the other enabled sources.
+	Enrich bool `json:"enrich"`
+	// Merge overrides the default merge rules by Book field name.
+	Merge merge.Policy `json:"merge"`
+	// Ask prompts for the value to keep when sources disagree.
+	Ask bool `json:"ask"`
+	// Auto picks the best search result without asking when its relevance
+	// is at least AutoThreshold; otherwise the results are listed to choose
+	// from, or skipped when there is no input.
+	Auto          bool    `json:"auto"`
+	AutoThreshold float64 `json:"auto_threshold"`
+	// Timeout bounds every search and enrichment.
+	Timeout Duration `json:"timeout"`
+	// Delays sets the minimal pause between requests to a domain.
+	Delays map[string]Duration `json:"delays"`
+	// Update merges fresh metadata into notes that already exist.
+	Update bool        `json:"update"`
+	Cache  CacheConfig `json:"cache"`
+	// SitesDir holds site definitions of stores to scrape in addition to
+	// the built-in ones, or in place of those with the same name.
+	SitesDir string `json:"sites_dir"`
+	// DoctorLinks replaces the pages book doctor checks by source name.
+	DoctorLinks map[string][]string `json:"doctor_links"`
+	// Mirrors sends the requests for a host to another base URL, e.g. a
+	// local server with saved pages.
+	Mirrors map[string]string `json:"mirrors"`
+	// Offline answers every request from the cache.
+	Offline bool `json:"-"`
+	// Yes writes updated notes without asking.
+	Yes bool `json:"-"`
+	// DryRun shows the notes that would be written instead of writing them.
+	DryRun bool `json:"-"`
+	// Json and Jsonl print books and search results as JSON instead of
+	// writing notes, see the schema package. Jsonl prints one object per
+	// line.
+	Json  bool `json:"-"`
+	Jsonl bool `json:"-"`
+	// NoInput never reads from the terminal: results that would need a
+	// choice are skipped and conflicts are merged without asking.
+	NoInput bool `json:"-"`
+}
+
+// CacheConfig tells where fetched pages and search answers are kept and
+// for how long they are used instead of asking the sites again.
+type CacheConfig struct {
+	Dir string `json:"dir"`
+	// TTL sets how long pages stay fresh by domain; other domains use
+	// DefaultTTL.
+	TTL        map[string]Duration `json:"ttl"`
+	DefaultTTL Duration            `json:"default_ttl"`
+	Disabled   bool                `json:"disabled"`
+}
+
+var config Config
+
+func DefaultConfig() Config {
+	return Config{
+		Search: sources.SearchSettings{
+			Backends: []string{"google", "site", "duckduckgo"},
+		},
+		OutputDir: ".",
+		Enrich:    true,
+		// most query words are in the title or authors and most title words
+		// in the query
+		AutoThreshold: 0.6,
+		Merge:         merge.DefaultPolicy(),
+		Timeout:       Duration{2 * time.Minute},
+		Delays: map[string]Duration{
+			"labirint.ru":     {1 * time.Second},
+			"livelib.ru":      {1 * time.Second},
+			"goodreads.com":   {1 * time.Second},
+			"litres.ru":       {1 * time.Second},
+			"chitai-gorod.ru": {1 * time.Second},
+			"ozon.ru":         {2 * time.Second},
+		},
+		Cache: CacheConfig{
+			Dir: DefaultCacheDir(),
+			TTL: map[string]Duration{
+				"labirint.ru":     {3 * 24 * time.Hour},
+				"litres.ru":       {3 * 24 * time.Hour},
+				"chitai-gorod.ru": {3 * 24 * time.Hour},
+				"ozon.ru":         {3 * 24 * time.Hour},
+				"livelib.ru":      {30 * 24 * time.Hour},
+				"goodreads.com":   {30 * 24 * time.Hour},
+				"googleapis.com":  {24 * time.Hour},
+				"duckduckgo.com":  {24 * time.Hour},
+			},
+			DefaultTTL: Duration{7 * 24 * time.Hour},
+		},
+		SitesDir:    filepath.Join(filepath.Dir(DefaultConfigPath()), "sites"),
+		LibraryRoot: "/Lib/",
+		Sources:     sources.DefaultOrder(),
+		Translator: translate.Settings{
+			File: filepath.Join(filepath.Dir(DefaultConfigPath()), "translations.json"),
+			From: "ru",
+			To:   "en",
+		},
+	}
+}
+
+// DefaultConfigPath returns $XDG_CONFIG_HOME/book-scrapper/config.json or
+// its platform equivalent.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "book-scrapper", "config.json")
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/book-scrapper or its platform
+// equivalent.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "book-scrapper")
+}
+
+// ReadFile merges the settings found in the JSON file into the config.
+// Keys missing from the file keep their current values.
+func (config *Config) ReadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(content, config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadEnv applies the BOOK_* environment variables.
+func (config *Config) ReadEnv() {
+	setFromEnv(&config.Search.ApiKey, "BOOK_GOOGLE_API_KEY")
+	setFromEnv(&config.Search.Cx, "BOOK_GOOGLE_CX")
+	if s := os.Getenv("BOOK_SEARCH"); s != "" {
+		config.Search.Backends = splitList(s)
+	}
+	setFromEnv(&config.OutputDir, "BOOK_OUTPUT_DIR")
+	setFromEnv(&config.LibraryRoot, "BOOK_LIBRARY_ROOT")
+	setFromEnv(&config.Template, "BOOK_TEMPLATE")
+	setFromEnv(&config.SitesDir, "BOOK_SITES_DIR")
+	setFromEnv(&config.Cache.Dir, "BOOK_CACHE_DIR")
+	setFromEnv(&config.Translator.File, "BOOK_TRANSLATIONS")
+	setFromEnv(&config.Translator.From, "BOOK_TRANSLATE_FROM")
+	setFromEnv(&config.Translator.To, "BOOK_TRANSLATE_TO")
+	for lang := range config.Sources {
+		if s := os.Getenv("BOOK_SOURCES_" + strings.ToUpper(lang)); s != "" {
+			config.Sources[lang] = splitList(s)
+		}
+	}
+}
+
+func setFromEnv(field *string, name string) {
+	if s := os.Getenv(name); s != "" {
+		*field = s
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// NewScrapper sets up a scrapper with the configured sources, site
+// definitions, request delays, cache, search backends and merge rules.
+func (config *Config) NewScrapper(translator sources.Translator) (*bookscrapper.Scrapper, error) {
+	env := sources.NewEnv()
+	env.Translator = translator
+	env.Logf = func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+	for domain, delay := range config.Delays {
+		env.Limiter.SetDelay(domain, delay.Duration)
+	}
+	env.Mirrors = config.Mirrors
+	if cache := config.NewCache(); cache != nil {
+		env.Cache = cache
+	} else if config.Offline {
+		return nil, errors.New("-offline needs the cache, which is disabled")
+	}
+	sites, err := sources.LoadSites(config.SitesDir)
+	if err != nil {
+		return nil, err
+	}
+	search := config.Search
+	search.Sites = make(map[string]sources.SiteSearch)
+	for _, site := range sites {
+		if site.SiteSearch != nil {
+			search.Sites[site.Domains[0]] = *site.SiteSearch
+		}
+	}
+	for domain, s := range config.Search.Sites {
+		search.Sites[domain] = s
+	}
+	searcher, err := sources.NewSearcher(search, env)
+	if err != nil {
+		return nil, err
+	}
+	env.Searcher = searcher
+
+	scrapper := bookscrapper.New(env)
+	scrapper.Registry.Replace(sources.GoogleBooksSource{Env: env, ApiKey: config.Search.ApiKey})
+	for _, site := range sites {
+		source, err := sources.NewSiteSource(site, env)
+		if err != nil {
+			return nil, err
+		}
+		scrapper.Registry.Replace(source)
+	}
+	for lang, names := range config.Sources {
+		if err := scrapper.Registry.SetOrder(lang, names); err != nil {
+			return nil, fmt.Errorf("sources for %q: %w", lang, err)
+		}
+	}
+	scrapper.Policy = config.Merge
+	scrapper.Resolver = config.Resolver()
+	return scrapper, nil
+}
+
+// NewCache returns the configured cache, or nil when it is disabled.
+func (config *Config) NewCache() *sources.Cache {
+	if config.Cache.Disabled || config.Cache.Dir == "" {
+		return nil
+	}
+	cache := sources.NewCache(config.Cache.Dir, config.Cache.DefaultTTL.Duration)
+	for domain, ttl := range config.Cache.TTL {
+		cache.TTL[domain] = ttl.Duration
+	}
+	cache.Offline = config.Offline
+	return cache
+}
+
+// WithTimeout returns a context for one round of network lookups. It ends
+// after the configured timeout or when the user interrupts the lookup;
+// outside of it an interrupt stops the program as usual.
+func (config *Config) WithTimeout() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout.Duration)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// TemplatesDir is where user templates are looked up by name.
+func TemplatesDir() string {
+	return filepath.Join(filepath.Dir(DefaultConfigPath()), "templates")
+}
+
+// Duration is a time.Duration written as "30s" or "2m" in the config file.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// LoadConfig builds the configuration from defaults, the config file, the
+// environment and the command line flags of the command, and returns the
+// remaining arguments.
+func LoadConfig(cmd *Command, args []string) (Config, []string, error) {
+	config := DefaultConfig()
+
+	flags := flag.NewFlagSet("book "+cmd.Name, flag.ExitOnError)
+	flags.Usage = func() {
+		cmd.PrintHelp(flags.Output())
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+	}
+	path := flags.String("config", "", "config file (default "+DefaultConfigPath()+")")
+	out := flags.String("out", "", "directory notes are written to")
+	library := flags.String("library", "", "root of the library folder linked from notes")
+	tmpl := flags.String("template", "", "note template file or name in "+TemplatesDir())
+	backends := flags.String("search", "", "comma separated search backends to try, in order")
+	keyFile := flags.String("api-key-file", "", "file holding the Google API key, kept off the command line where other users could see it")
+	cx := flags.String("cx", "", "Google Custom Search engine id")
+	order := flags.String("source", "", "comma separated sources to use, in order")
+	translations := flags.String("translations", "", "tag translations file")
+	enrich := flags.Bool("enrich", true, "fill missing fields from the other sources")
+	auto := flags.Bool("auto", false, "pick the best result without asking if it is relevant enough")
+	threshold := flags.Float64("threshold", 0, "relevance from 0 to 1 the best result needs for -auto (default 0.6)")
+	timeout := flags.Duration("timeout", 0, "give up searching after this long")
+	ask := flags.Bool("ask", false, "ask which value to keep when sources disagree")
+	update := flags.Bool("update", false, "refresh existing notes, keeping what you wrote in them")
+	yes := flags.Bool("yes", false, "write updated notes without asking")
+	dryRun := flags.Bool("dry-run", false, "show the notes that would be written without writing them")
+	jsonOut := flags.Bool("json", false, "print books and search results as JSON instead of writing notes")
+	jsonl := flags.Bool("jsonl", false, "like -json, one object per line")
+	offline := flags.Bool("offline", false, "use only cached pages and search results")
+	noCache := flags.Bool("no-cache", false, "neither use nor fill the cache")
+
+	// flags may also follow the arguments, as in "book vault list -json";
+	// everything after "--" is an argument
+	var rest []string
+	for len(args) > 0 {
+		flags.Parse(args)
+		parsed := args[:len(args)-flags.NArg()]
+		args = flags.Args()
+		if len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			rest = append(rest, args...)
+			break
+		}
+		if len(args) > 0 {
+			rest = append(rest, args[0])
+			args = args[1:]
+		}
+	}
+
+	if *path != "" {
+		if err := config.ReadFile(*path); err != nil {
+			return config, nil, err
+		}
+	} else if p := DefaultConfigPath(); p != "" {
+		err := config.ReadFile(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return config, nil, err
+		}
+	}
+	config.ReadEnv()
+
+	setFromFlag(&config.OutputDir, *out)
+	setFromFlag(&config.LibraryRoot, *library)
+	setFromFlag(&config.Template, *tmpl)
+	setFromFlag(&config.Translator.File, *translations)
+	setFromFlag(&config.Search.Cx, *cx)
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return config, nil, err
+		}
+		config.Search.ApiKey = strings.TrimSpace(string(key))
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "enrich" {
+			config.Enrich = *enrich
+		}
+	})
+	config.Auto = config.Auto || *auto
+	if *threshold > 0 {
+		config.AutoThreshold = *threshold
+	}
+	if *timeout > 0 {
+		config.Timeout.Duration = *timeout
+	}
+	config.Ask = config.Ask || *ask
+	config.Update = config.Update || *update
+	config.Yes = *yes
+	config.DryRun = *dryRun
+	config.Json = *jsonOut
+	config.Jsonl = *jsonl
+	config.NoInput = config.JsonOutput()
+	config.Offline = *offline
+	config.Cache.Disabled = config.Cache.Disabled || *noCache
+	if *backends != "" {
+		config.Search.Backends = splitList(*backends)
+	}
+	if *order != "" {
+		for lang := range config.Sources {
+			config.Sources[lang] = splitList(*order)
+		}
+	}
+
+	return config, rest, nil
+}
+
+// JsonOutput reports whether results are printed as JSON.
+func (config *Config) JsonOutput() bool {
+	return config.Json || config.Jsonl
+}
+
+// Resolver returns how merge conflicts are settled.
+func (config *Config) Resolver() merge.Resolver {
+	if config.Ask && !config.NoInput {
+		return PromptResolver{}
+	}
+	return nil
+}
+
+func setFromFlag(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// isolate points the config directory at an empty temporary one and
+// clears the BOOK_* variables, returning the config directory of book.
+func isolate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	for _, name := range []string{"BOOK_GOOGLE_API_KEY", "BOOK_GOOGLE_CX", "BOOK_OUTPUT_DIR", "BOOK_TRANSLATIONS"} {
+		t.Setenv(name, "")
+	}
+	return filepath.Join(dir, "book-scrapper")
+}
+
+func TestLoadConfigArgs(t *testing.T) {
+	isolate(t)
+	tests := []struct {
+		args []string
+		rest []string
+		json bool
+		out  string
+	}{
+		{[]string{"list"}, []string{"list"}, false, "."},
+		{[]string{"-json", "list"}, []string{"list"}, true, "."},
+		{[]string{"list", "-json"}, []string{"list"}, true, "."},
+		{[]string{"Мастер", "-out", "notes", "и", "Маргарита"}, []string{"Мастер", "и", "Маргарита"}, false, "notes"},
+		{[]string{"-out=notes", "update", "a.md", "-json", "b.md"}, []string{"update", "a.md", "b.md"}, true, "notes"},
+		// everything after -- is an argument
+		{[]string{"-json", "--", "-out", "notes"}, []string{"-out", "notes"}, true, "."},
+		{[]string{"a.md", "--", "-b.md"}, []string{"a.md", "-b.md"}, false, "."},
+	}
+	for _, test := range tests {
+		config, rest, err := LoadConfig(lookupCommand, test.args)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(rest, test.rest) || config.Json != test.json || config.OutputDir != test.out {
+			t.Errorf("%q: got %q, json %v, out %q", test.args, rest, config.Json, config.OutputDir)
+		}
+	}
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	dir := isolate(t)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := `{"output_dir": "file", "library_root": "/Books/", "search": {"api_key": "file-key", "cx": "file-cx"}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("flag-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOOK_OUTPUT_DIR", "env")
+	t.Setenv("BOOK_GOOGLE_CX", "env-cx")
+
+	config, _, err := LoadConfig(lookupCommand, []string{"-api-key-file", keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{config.LibraryRoot, config.OutputDir, config.Search.Cx, config.Search.ApiKey, config.Translator.File}
+	want := []string{"/Books/", "env", "env-cx", "flag-key", filepath.Join(dir, "translations.json")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	config, _, err = LoadConfig(lookupCommand, []string{"-out", "flag", "-cx", "flag-cx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.OutputDir != "flag" || config.Search.Cx != "flag-cx" || config.Search.ApiKey != "file-key" {
+		t.Errorf("got out %q, cx %q, key %q", config.OutputDir, config.Search.Cx, config.Search.ApiKey)
+	}
+
+	if _, _, err := LoadConfig(lookupCommand, []string{"-api-key-file", filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing key file is not an error")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// Exit codes of the command line tool.
+const (
+	ExitOK         = 0
+	ExitFailure    = 1
+	ExitUsage      = 2
+	ExitNotFound   = 3
+	ExitBlocked    = 4
+	ExitQuota      = 5
+	ExitIncomplete = 6
+)
+
+// errUsage marks errors in the command line itself.
+var errUsage = errors.New("wrong usage")
+
+// reported wraps errors that were already shown to the user as they
+// happened; they only decide the exit code.
+type reported struct {
+	error
+}
+
+func (r reported) Unwrap() error {
+	return r.error
+}
+
+// ExitCode returns the exit code reporting the most telling of the errors:
+// quota and blocking problems before parse failures before missing books.
+// Nil errors are ignored.
+func ExitCode(errs ...error) int {
+	codes := []struct {
+		err  error
+		code int
+	}{
+		{errUsage, ExitUsage},
+		{sources.ErrQuotaExceeded, ExitQuota},
+		{sources.ErrBlocked, ExitBlocked},
+		{sources.ErrIncomplete, ExitIncomplete},
+		{sources.ErrNotFound, ExitNotFound},
+		{sources.ErrNotCached, ExitNotFound},
+	}
+	for _, c := range codes {
+		for _, err := range errs {
+			if errors.Is(err, c.err) {
+				return c.code
+			}
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return ExitFailure
+		}
+	}
+	return ExitOK
+}
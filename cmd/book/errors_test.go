@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		errs []error
+		want int
+	}{
+		{nil, ExitOK},
+		{[]error{nil, nil}, ExitOK},
+		{[]error{errors.New("disk full")}, ExitFailure},
+		{[]error{fmt.Errorf("%w: no query", errUsage)}, ExitUsage},
+		{[]error{fmt.Errorf("labirint: %w", sources.ErrNotFound)}, ExitNotFound},
+		{[]error{sources.ErrNotCached}, ExitNotFound},
+		{[]error{sources.ErrIncomplete}, ExitIncomplete},
+		{[]error{sources.ErrBlocked}, ExitBlocked},
+		{[]error{sources.ErrQuotaExceeded}, ExitQuota},
+		// the most telling error decides
+		{[]error{sources.ErrNotFound, nil, sources.ErrIncomplete}, ExitIncomplete},
+		{[]error{sources.ErrIncomplete, sources.ErrBlocked}, ExitBlocked},
+		{[]error{sources.ErrBlocked, sources.ErrQuotaExceeded}, ExitQuota},
+		{[]error{errors.New("disk full"), sources.ErrNotFound}, ExitNotFound},
+		// errors already shown and joined ones still count
+		{[]error{reported{errors.Join(sources.ErrNotFound, sources.ErrBlocked)}}, ExitBlocked},
+	}
+	for _, test := range tests {
+		if got := ExitCode(test.errs...); got != test.want {
+			t.Errorf("%v: got %d, want %d", test.errs, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Command book looks a book up on bookstores and catalogues and writes an
+// Obsidian note for it.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jupy/book-scrapper/bookscrapper"
+	"github.com/jupy/book-scrapper/bookscrapper/translate"
+)
+
+// Command is one of the subcommands of book.
+type Command struct {
+	Name string
+	// Args describes the arguments, e.g. "<query>".
+	Args  string
+	Short string
+	Long  string
+	Run   func(app *App, args []string) error
+	// Standalone commands run without the configuration, the translator
+	// and the scrapper, and get no App.
+	Standalone bool
+}
+
+// App is what the commands work with.
+type App struct {
+	Scrapper   *bookscrapper.Scrapper
+	Translator *translate.TagTranslator
+}
+
+// lookupCommand runs when no command is named, guessing what to do from
+// the arguments as earlier versions did.
+var lookupCommand = &Command{
+	Args:  "<query | url... | isbn>",
+	Short: "search, fetch or look up an ISBN depending on the arguments",
+	Run:   runLookup,
+}
+
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		searchCommand,
+		fetchCommand,
+		isbnCommand,
+		batchCommand,
+		updateCommand,
+		translationsCommand,
+		vaultCommand,
+		cacheCommand,
+		doctorCommand,
+		{
+			Name:       "help",
+			Args:       "[command]",
+			Short:      "show help for a command",
+			Run:        runHelp,
+			Standalone: true,
+		},
+		{
+			Name:       "version",
+			Short:      "show the version of book",
+			Run:        runVersion,
+			Standalone: true,
+		},
+	}
+}
+
+func findCommand(name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// PrintHelp describes the command and its arguments.
+func (cmd *Command) PrintHelp(w io.Writer) {
+	name := "book"
+	if cmd.Name != "" {
+		name += " " + cmd.Name
+	}
+	fmt.Fprintf(w, "Usage: %s [flags] %s\n\n%s\n", name, cmd.Args, cmd.Short)
+	if cmd.Long != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(cmd.Long))
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: book <command> [flags] [arguments]")
+	fmt.Fprintln(w, "       book [flags] <query | url... | isbn>")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-13s %s\n", cmd.Name, cmd.Short)
+	}
+	fmt.Fprintln(w, "\nRun \"book help <command>\" for the arguments and flags of a command.")
+}
+
+func runHelp(app *App, args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+	LoadConfig(cmd, []string{"-h"})
+	return nil
+}
+
+func runVersion(app *App, args []string) error {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	fmt.Printf("book %s\n", version)
+	return nil
+}
+
+// parseCommand returns the command named by the first argument and the
+// arguments left for it. Arguments naming no command are for the lookup,
+// as in "book Мастер и Маргарита".
+func parseCommand(args []string) (*Command, []string) {
+	switch args[0] {
+	case "-h", "-help", "--help":
+		args = []string{"help"}
+	case "-version", "--version":
+		args = []string{"version"}
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd, args[1:]
+	}
+	return lookupCommand, args
+}
+
+// fail reports the error and exits with the code telling what went wrong.
+func fail(err error, code int) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(code)
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		os.Exit(ExitUsage)
+	}
+	cmd, args := parseCommand(args)
+	// help and version must work even when the config file is broken
+	if cmd.Standalone {
+		err := cmd.Run(nil, args)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(ExitCode(err))
+	}
+
+	var err error
+	config, args, err = LoadConfig(cmd, args)
+	if err != nil {
+		fail(err, ExitUsage)
+	}
+	if config.JsonOutput() {
+		progress = os.Stderr
+	}
+	translator, err := translate.Load(config.Translator)
+	if err != nil {
+		fail(err, ExitFailure)
+	}
+	scrapper, err := config.NewScrapper(translator)
+	if err != nil {
+		fail(err, ExitUsage)
+	}
+
+	err = cmd.Run(&App{Scrapper: scrapper, Translator: translator}, args)
+	var r reported
+	if err != nil && !errors.As(err, &r) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "Run \"%s\" for usage.\n", strings.TrimSpace("book help "+cmd.Name))
+	}
+
+	if saveErr := translator.Save(); saveErr != nil {
+		fail(saveErr, ExitFailure)
+	}
+	os.Exit(ExitCode(err))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		name string
+		rest []string
+	}{
+		{[]string{"search", "Мастер и Маргарита"}, "search", []string{"Мастер и Маргарита"}},
+		{[]string{"vault", "list", "-json"}, "vault", []string{"list", "-json"}},
+		{[]string{"-json", "search", "Дюна"}, "", []string{"-json", "search", "Дюна"}},
+		{[]string{"Мастер", "и", "Маргарита"}, "", []string{"Мастер", "и", "Маргарита"}},
+		{[]string{"https://fantlab.ru/work2"}, "", []string{"https://fantlab.ru/work2"}},
+		{[]string{"--help"}, "help", []string{}},
+		{[]string{"-h"}, "help", []string{}},
+		{[]string{"help", "fetch"}, "help", []string{"fetch"}},
+		{[]string{"--version"}, "version", []string{}},
+	}
+	for _, test := range tests {
+		cmd, rest := parseCommand(test.args)
+		if cmd.Name != test.name || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("%q: got %q with %q", test.args, cmd.Name, rest)
+		}
+	}
+	if cmd, _ := parseCommand([]string{"Дюна"}); cmd != lookupCommand {
+		t.Error("a query does not run the lookup")
+	}
+	if cmd, _ := parseCommand([]string{"help"}); !cmd.Standalone {
+		t.Error("help needs the config")
+	}
+}
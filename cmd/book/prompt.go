@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jupy/book-scrapper/bookscrapper/match"
+	"github.com/jupy/book-scrapper/bookscrapper/merge"
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"github.com/jupy/book-scrapper/bookscrapper/sources"
+)
+
+// stdin is shared by all the prompts: a reader of their own would buffer
+// the answers piped in for the prompts after it.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ListBooks collects the books of a concurrent search, reporting each one
+// and each failed source as soon as it arrives.
+func ListBooks(results <-chan sources.Result) ([]merge.Candidate, []error) {
+	var list []merge.Candidate
+	var errs []error
+	for r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", r.Err)
+			errs = append(errs, r.Err)
+			continue
+		}
+		fmt.Fprintf(progress, "found: \"%s\" %v\n", r.Book.FileName, r.Book.FoundOn())
+		list = append(list, merge.Candidate{Source: r.Source, Book: r.Book})
+	}
+	return list, errs
+}
+
+// SelectBook asks which of the found editions to use and returns it merged
+// from all the stores it was found on.
+func SelectBook(editions []match.Edition) *model.Book {
+	var i int
+
+	if len(editions) > 0 && config.Auto && editions[0].Score >= config.AutoThreshold {
+		fmt.Fprintf(progress, "picked \"%s\" (%.2f)\n", editions[0].Books[0].FileName, editions[0].Score)
+		book := editions[0].Merge(config.Merge, config.Resolver())
+		return &book
+	}
+
+	if len(editions) > 0 && config.NoInput {
+		fmt.Fprintf(progress, "skipped: no result is relevant enough to pick without asking\n")
+	} else if len(editions) > 0 {
+		fmt.Fprintf(progress, "=======\n")
+		fmt.Fprintf(progress, "0. none \n")
+		for i, edition := range editions {
+			book := edition.Books[0]
+			fmt.Fprintf(progress, "%d. (%.2f) \"%s\" [%s] publisher: %s\n", i+1, edition.Score, book.FileName, book.Year, book.Publisher)
+			fmt.Fprintf(progress, "        stores: %s\n", strings.Join(edition.Stores(), ", "))
+			for _, b := range edition.Books {
+				for _, name := range b.FoundOn() {
+					fmt.Fprintf(progress, "        %s\n", b.SourceUrl(name))
+				}
+			}
+		}
+		text, _ := stdin.ReadString('\n')
+		text = strings.TrimSuffix(text, "\n")
+		if text == "" {
+			text = "0"
+		}
+		i, _ = strconv.Atoi(text)
+	} else if len(editions) == 0 {
+		fmt.Fprintln(progress, "Nothing found")
+	}
+
+	if i <= 0 || i > len(editions) {
+		return nil
+	}
+	book := editions[i-1].Merge(config.Merge, config.Resolver())
+	return &book
+}
+
+// PromptResolver asks on the terminal which value to keep.
+type PromptResolver struct{}
+
+func (PromptResolver) Resolve(field string, options []merge.Option, pick int) int {
+	fmt.Fprintf(progress, "=======\n")
+	fmt.Fprintf(progress, "sources disagree on %s:\n", field)
+	for i, o := range options {
+		value := o.Value
+		if utf8.RuneCountInString(value) > 100 {
+			value = string([]rune(value)[:100]) + "..."
+		}
+		fmt.Fprintf(progress, "%d. %s [%s]\n", i+1, value, strings.Join(o.Sources, ", "))
+	}
+	fmt.Fprintf(progress, "keep [%d]: ", pick+1)
+	text, _ := stdin.ReadString('\n')
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return pick
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 1 || n > len(options) {
+		return pick
+	}
+	return n - 1
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jupy/book-scrapper/bookscrapper/model"
+	"github.com/jupy/book-scrapper/bookscrapper/render"
+)
+
+// NotePath is where the note for the book is written.
+func NotePath(book *model.Book) string {
+	return filepath.Join(config.OutputDir, book.FileName)
+}
+
+// SaveMarkdown writes the note for the book to path. An existing note is
+// never overwritten: in update mode the fresh metadata is merged into it and
+// the changes are shown before writing, otherwise saving fails. A dry run
+// only shows what would be written.
+func SaveMarkdown(book *model.Book, path string) error {
+	tmpl, err := render.LoadTemplate(config.Template, TemplatesDir())
+	if err != nil {
+		return err
+	}
+
+	var fresh strings.Builder
+	if err = render.RenderNote(&fresh, tmpl, book, config.LibraryRoot); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if config.DryRun {
+			fmt.Fprintln(progress, "file \""+path+"\" would be created:")
+			fmt.Print(fresh.String())
+			return nil
+		}
+		err = os.WriteFile(path, []byte(fresh.String()), 0644)
+		if err == nil {
+			fmt.Fprintln(progress, "file \""+path+"\" created")
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !config.Update {
+		return fmt.Errorf("file \"%s\" already exists, use -update to refresh it", path)
+	}
+	old := string(content)
+	merged := render.MergeNote(old, fresh.String())
+	if merged == old {
+		fmt.Fprintln(progress, "file \""+path+"\" is up to date")
+		return nil
+	}
+	for _, line := range render.LineDiff(old, merged) {
+		fmt.Fprintln(progress, line)
+	}
+	if config.DryRun {
+		fmt.Fprintln(progress, "file \""+path+"\" would be updated")
+		return nil
+	}
+	if !config.Yes {
+		if config.NoInput {
+			fmt.Fprintln(progress, "file \""+path+"\" not updated, use -yes to update without asking")
+			return nil
+		}
+		if !Confirm("update \"" + path + "\"?") {
+			return nil
+		}
+	}
+	err = os.WriteFile(path, []byte(merged), 0644)
+	if err == nil {
+		fmt.Fprintln(progress, "file \""+path+"\" updated")
+	}
+	return err
+}
+
+// Confirm asks a yes/no question on the terminal, defaulting to no.
+func Confirm(question string) bool {
+	fmt.Fprintf(progress, "%s [y/N] ", question)
+	text, _ := stdin.ReadString('\n')
+	text = strings.ToLower(strings.TrimSpace(text))
+	return text == "y" || text == "yes"
+}